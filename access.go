@@ -0,0 +1,129 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrFieldNotAllowed is returned, wrapped, when a query uses a field path excluded by
+// WithAllowedFields or WithDeniedFields. The error is the same whether or not the
+// field exists.
+var ErrFieldNotAllowed = errors.New("field is not allowed")
+
+// WithAllowedFields restricts queries to the given field paths. A pattern is either an
+// exact path ("Department.Name") or a prefix glob ending in ".*" that matches every
+// path below it ("Tags.*"); "*" matches everything. Patterns are case-insensitive.
+func WithAllowedFields(patterns ...string) Option {
+	return func(o *options) {
+		o.allowedFields = append(o.allowedFields, patterns...)
+	}
+}
+
+// WithDeniedFields rejects queries that use the given field paths. Patterns have the
+// same form as for WithAllowedFields, and a denied path is rejected even if it is
+// also allowed. A denied field is rejected under its aliases too, which tells the
+// caller that the alias exists; use WithAllowedFields to hide which names exist.
+func WithDeniedFields(patterns ...string) Option {
+	return func(o *options) {
+		o.deniedFields = append(o.deniedFields, patterns...)
+	}
+}
+
+// fieldPatternMatches reports whether a field path matches an allow/deny pattern. In
+// the path, a * segment from a wildcard stands for any one name, a [*] segment for
+// none or any one, and a ** segment from recursive descent for any number of names:
+// with some set the path matches if any names it stands for could match, otherwise
+// only if all of them do.
+func fieldPatternMatches(pattern, path string, some bool) bool {
+	pattern = foldKey(strings.TrimSpace(pattern))
+	if pattern == "*" {
+		return true
+	}
+	names := strings.Split(foldKey(path), ".")
+	prefix, glob := strings.CutSuffix(pattern, ".*")
+	patternNames := strings.Split(prefix, ".")
+	if some {
+		return couldMatch(names, patternNames, glob)
+	}
+
+	// Every field the path reaches matches if its names up to the end of the
+	// pattern are fixed and equal, and anything after is covered by the glob
+	if len(names) < len(patternNames) {
+		return false
+	}
+	for i, name := range patternNames {
+		if names[i] != name || name == "*" || name == "**" || name == elementsName {
+			return false
+		}
+	}
+	rest := names[len(patternNames):]
+	if !glob {
+		return len(rest) == 0
+	}
+	for _, name := range rest {
+		if name != "**" && name != elementsName {
+			return true
+		}
+	}
+	return false
+}
+
+// couldMatch reports whether some field path that names stands for matches the
+// pattern names, followed by at least one more name if glob is set
+func couldMatch(names, patternNames []string, glob bool) bool {
+	switch {
+	case len(patternNames) == 0 && glob:
+		return len(names) > 0
+	case len(names) == 0:
+		return len(patternNames) == 0
+	case names[0] == "**":
+		// ** stands for no names, or for a pattern name and maybe more
+		return couldMatch(names[1:], patternNames, glob) ||
+			len(patternNames) > 0 && couldMatch(names, patternNames[1:], glob)
+	case names[0] == elementsName:
+		// [*] stands for no names, or for one
+		return couldMatch(names[1:], patternNames, glob) ||
+			len(patternNames) > 0 && couldMatch(names[1:], patternNames[1:], glob)
+	case len(patternNames) == 0:
+		return false
+	case names[0] == "*" || names[0] == patternNames[0]:
+		return couldMatch(names[1:], patternNames[1:], glob)
+	}
+	return false
+}
+
+// fieldAllowed reports whether the options permit querying any of the given spellings
+// of a field path. A path is rejected if any spelling is denied, and accepted if any
+// spelling is allowed. A path with wildcards is rejected if any field it can reach is
+// denied, and accepted only if every field it can reach is allowed.
+func (o *options) fieldAllowed(paths ...string) bool {
+	for _, path := range paths {
+		for _, pattern := range o.deniedFields {
+			if fieldPatternMatches(pattern, path, true) {
+				return false
+			}
+		}
+	}
+	if len(o.allowedFields) == 0 {
+		return true
+	}
+	for _, path := range paths {
+		for _, pattern := range o.allowedFields {
+			if fieldPatternMatches(pattern, path, false) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkFieldAccess returns an ErrFieldNotAllowed error naming path, as written in the
+// query, if the options forbid its spellings: the path without quoting and its Go names
+func (o *options) checkFieldAccess(path string, spellings ...string) error {
+	// TYPE() of the item itself reads no field
+	if len(splitPath(path)) == 0 || o.fieldAllowed(spellings...) {
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrFieldNotAllowed, path)
+}
@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+)
+
+type Account struct {
+	BaseModel
+	Name         string
+	Email        string `json:"email"`
+	PasswordHash string `json:"password_hash"`
+	Nickname     string `parser:"nick,alias=handle"`
+	Internal     struct {
+		Score int
+		Notes string
+	}
+	Tags map[string]string
+}
+
+func TestFieldAccessOptions(t *testing.T) {
+	accounts := []Account{
+		{BaseModel: BaseModel{ID: 1}, Name: "alice", Email: "a@x", PasswordHash: "h1", Nickname: "al", Tags: map[string]string{"team": "core"}},
+		{BaseModel: BaseModel{ID: 2}, Name: "bob", Email: "b@x", PasswordHash: "h2", Nickname: "bo", Tags: map[string]string{"team": "web"}},
+	}
+	public := WithAllowedFields("ID", "Name", "email", "nick", "Tags.*")
+	secret := WithDeniedFields("PasswordHash", "Internal.*", "Nickname")
+
+	tests := []struct {
+		name    string
+		query   string
+		opts    []Option
+		want    int
+		blocked bool
+	}{
+		{"Allowed exact path", "Name = 'alice'", []Option{public}, 1, false},
+		{"Allowed path is case-insensitive", "NAME = 'alice'", []Option{public}, 1, false},
+		{"Allowed prefix glob", "Tags.team = 'web'", []Option{public}, 1, false},
+		{"Allowed promoted field", "ID = 2", []Option{public}, 1, false},
+		{"Field outside allowlist", "PasswordHash = 'h1'", []Option{public}, 0, true},
+		{"Unknown field gives the same error", "NoSuchField = 'x'", []Option{public}, 0, true},
+		{"Glob does not match the prefix itself", "Tags IS NULL", []Option{public}, 0, true},
+		{"Allowlist inside OR", "Name = 'alice' OR PasswordHash = 'h2'", []Option{public}, 0, true},
+		{"Allowlist inside ANY", "ANY(PasswordHash) = ANY('h1')", []Option{public}, 0, true},
+		{"Allowlist inside function", "LOWER(PasswordHash) = 'h1'", []Option{public}, 0, true},
+		{"Allowlist inside IS NULL", "PasswordHash IS NULL", []Option{public}, 0, true},
+		{"Allowed by JSON name reaches the field", "email = 'a@x'", []Option{public, WithJSONTags()}, 1, false},
+		{"Allowed by Go name reached via JSON name", "Email = 'a@x'", []Option{WithAllowedFields("Email"), WithJSONTags()}, 1, false},
+		{"Denied exact path", "PasswordHash = 'h1'", []Option{secret}, 0, true},
+		{"Denied prefix glob", "Internal.Score > 0", []Option{secret}, 0, true},
+		{"Denied unknown path below glob", "Internal.Missing > 0", []Option{secret}, 0, true},
+		{"Denied glob via KEYS", "KEYS(Internal) CONTAINS 'sec'", []Option{secret}, 0, true},
+		{"Denied glob via VALUES", "VALUES(Internal) = 'x'", []Option{secret}, 0, true},
+		{"Allowed glob via KEYS", "KEYS(Tags) = 'team'", []Option{public}, 2, false},
+		{"Other fields still allowed", "Name = 'bob'", []Option{secret}, 1, false},
+		{"Denied field via JSON name", "password_hash = 'h1'", []Option{secret, WithJSONTags()}, 0, true},
+		{"Denied field via parser tag alias", "handle = 'al'", []Option{secret}, 0, true},
+		{"Denied field via embedded path", "BaseModel.ID = 1", []Option{WithDeniedFields("ID")}, 0, true},
+		{"Deny wins over allow", "Tags.team = 'core'", []Option{public, WithDeniedFields("Tags.team")}, 0, true},
+		{"Wildcard allow", "PasswordHash = 'h1'", []Option{WithAllowedFields("*")}, 1, false},
+		{"Denied map key with long s", "Tags.ſecret = 'x'", []Option{WithDeniedFields("Tags.secret")}, 0, true},
+		{"Denied bracket key with long s", "Tags['ſecret'] = 'x'", []Option{WithDeniedFields("Tags.secret")}, 0, true},
+		{"Denied map key with Kelvin sign", "Tags.\u212aey = 'x'", []Option{WithDeniedFields("Tags.key")}, 0, true},
+		{"Denied field with long s", "Paſſwordhash = 'h1'", []Option{secret}, 0, true},
+		{"Allowed map key with Kelvin sign", "Tags.\u212aey IS NULL", []Option{WithAllowedFields("Tags.key")}, 2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := Parse(tt.query, accounts, tt.opts...)
+			if tt.blocked {
+				if !errors.Is(err, ErrFieldNotAllowed) {
+					t.Fatalf("Parse(%q) expected ErrFieldNotAllowed, got %v", tt.query, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.query, err)
+			}
+			if len(results) != tt.want {
+				t.Errorf("Parse(%q) returned %d results, want %d", tt.query, len(results), tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldAccessCaseFolding(t *testing.T) {
+	// Map keys match case-insensitively, so a deny pattern must catch every spelling
+	// that folds to the denied key
+	records := []map[string]any{{"name": "a", "passwordhash": "secret"}}
+	for _, query := range []string{"paſswordhash = 'secret'", "PASSWORDHASH = 'secret'", "\u212a = 1 OR paſswordhash = 'secret'"} {
+		results, err := Parse(query, records, WithDeniedFields("PasswordHash"))
+		if !errors.Is(err, ErrFieldNotAllowed) {
+			t.Errorf("Parse(%q) = %v, %v, want ErrFieldNotAllowed", query, results, err)
+		}
+	}
+}
+
+func TestFieldAccessErrorDoesNotLeakExistence(t *testing.T) {
+	opt := WithAllowedFields("Name")
+	_, errExisting := Parse("PasswordHash = 'x'", []Account{}, opt)
+	_, errMissing := Parse("PasswordHashX = 'x'", []Account{}, opt)
+	if errExisting == nil || errMissing == nil {
+		t.Fatalf("expected both queries to be rejected, got %v and %v", errExisting, errMissing)
+	}
+	want := `failed to parse query: field is not allowed: "PasswordHash"`
+	if errExisting.Error() != want {
+		t.Errorf("got %q, want %q", errExisting.Error(), want)
+	}
+	if errMissing.Error() != `failed to parse query: field is not allowed: "PasswordHashX"` {
+		t.Errorf("unexpected error for missing field: %q", errMissing.Error())
+	}
+}
@@ -0,0 +1,379 @@
+package parser
+
+import (
+	"encoding"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// parameter is a bind placeholder in a query: ? (positional) or :name
+type parameter struct {
+	name  string // empty for positional parameters
+	index int    // position among the positional parameters of the query
+}
+
+func (p *parameter) String() string {
+	if p.name != "" {
+		return ":" + p.name
+	}
+	return fmt.Sprintf("?%d", p.index+1)
+}
+
+// parameter returns the placeholder for the current token if it is a PARAM, or nil
+func (p *Parser) parameter() *parameter {
+	if !p.currentTokenIs(PARAM) {
+		return nil
+	}
+	if name, ok := strings.CutPrefix(p.currentToken.Literal, ":"); ok {
+		return &parameter{name: name}
+	}
+	param := &parameter{index: p.positional}
+	p.positional++
+	return param
+}
+
+// addValue appends a value, and the parameter it comes from if any, to the list
+func (ae *AnyExpression) addValue(value string, param *parameter) {
+	if param != nil && ae.params == nil {
+		ae.params = make([]*parameter, len(ae.Values))
+	}
+	ae.Values = append(ae.Values, value)
+	ae.nums = append(ae.nums, literalNumber(value))
+	if ae.params != nil {
+		ae.params = append(ae.params, param)
+	}
+}
+
+// arg returns the Go value bound to the i-th value, or nil for literals
+func (ae *AnyExpression) arg(i int) any {
+	if ae.args == nil {
+		return nil
+	}
+	return ae.args[i]
+}
+
+// Bind returns a copy of q with its positional ? parameters set to args, in order.
+// Values are injected into the query as typed literals, never parsed as query text,
+// so they need no quoting. Named parameters are left for BindNamed.
+func (q *Query) Bind(args ...any) (*Query, error) {
+	if q == nil {
+		return nil, fmt.Errorf("query is nil")
+	}
+	if n := q.positionalParameters(); n != len(args) {
+		return nil, fmt.Errorf("query has %d positional parameters, got %d arguments", n, len(args))
+	}
+	return q.bind(func(p *parameter) (any, bool) {
+		if p.name != "" {
+			return nil, false
+		}
+		return args[p.index], true
+	})
+}
+
+// BindNamed returns a copy of q with its :name parameters set from args. Every
+// named parameter must be given, and every key must be used.
+func (q *Query) BindNamed(args map[string]any) (*Query, error) {
+	if q == nil {
+		return nil, fmt.Errorf("query is nil")
+	}
+	used := make(map[string]bool)
+	walkExpression(q.expr, func(e Expression) bool {
+		for _, p := range expressionParameters(e) {
+			if p.name != "" {
+				used[p.name] = true
+			}
+		}
+		return true
+	})
+	for name := range used {
+		if _, ok := args[name]; !ok {
+			return nil, fmt.Errorf("missing value for parameter :%s", name)
+		}
+	}
+	for name := range args {
+		if !used[name] {
+			return nil, fmt.Errorf("unknown parameter :%s", name)
+		}
+	}
+	return q.bind(func(p *parameter) (any, bool) {
+		if p.name == "" {
+			return nil, false
+		}
+		return args[p.name], true
+	})
+}
+
+func (q *Query) bind(lookup func(*parameter) (any, bool)) (*Query, error) {
+	expr, err := bindExpression(q.expr, lookup)
+	if err != nil {
+		return nil, err
+	}
+	bound := *q
+	bound.expr = expr
+	return &bound, nil
+}
+
+// positionalParameters returns the number of ? parameters in q that are not bound
+func (q *Query) positionalParameters() int {
+	n := 0
+	walkExpression(q.expr, func(e Expression) bool {
+		for _, p := range expressionParameters(e) {
+			if p.name == "" {
+				n++
+			}
+		}
+		return true
+	})
+	return n
+}
+
+// unboundParameter returns a parameter of expr that has not been bound, if any
+func unboundParameter(expr Expression) *parameter {
+	var unbound *parameter
+	walkExpression(expr, func(e Expression) bool {
+		if params := expressionParameters(e); len(params) > 0 {
+			unbound = params[0]
+		}
+		return unbound == nil
+	})
+	return unbound
+}
+
+// expressionParameters returns the unbound parameters used directly by expr
+func expressionParameters(expr Expression) []*parameter {
+	var params []*parameter
+	switch e := expr.(type) {
+	case *ComparisonExpression:
+		if e.param != nil {
+			params = append(params, e.param)
+		}
+	case *AnyExpression:
+		for _, p := range e.params {
+			if p != nil {
+				params = append(params, p)
+			}
+		}
+	}
+	return params
+}
+
+// bindExpression copies expr, replacing the parameters lookup knows with literals.
+// The original tree is not modified, so a compiled query can be bound many times.
+func bindExpression(expr Expression, lookup func(*parameter) (any, bool)) (Expression, error) {
+	switch e := expr.(type) {
+	case *ComparisonExpression:
+		if e.param == nil {
+			return e, nil
+		}
+		arg, ok := lookup(e.param)
+		if !ok {
+			return e, nil
+		}
+		if isListArg(arg) {
+			return nil, fmt.Errorf("parameter %s: cannot compare field '%s' with a list; use IN or ANY()", e.param, e.Field)
+		}
+		lit, err := bindLiteral(arg)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", e.param, err)
+		}
+		bound := *e
+		bound.Value, bound.param, bound.arg, bound.num = lit, nil, arg, literalNumber(lit)
+		return &bound, nil
+	case *AnyExpression:
+		if e.params == nil {
+			return e, nil
+		}
+		bound := *e
+		bound.Values, bound.params, bound.args, bound.nums = nil, nil, nil, nil
+		for i, value := range e.Values {
+			p := e.params[i]
+			arg, ok := any(nil), false
+			if p != nil {
+				arg, ok = lookup(p)
+			}
+			if !ok {
+				bound.addValue(value, p)
+				bound.addArg(len(bound.Values)-1, e.arg(i))
+				continue
+			}
+			// A list expands into one value per element, as in IN (?)
+			elems := []any{arg}
+			if isListArg(arg) {
+				v := reflect.ValueOf(arg)
+				elems = make([]any, v.Len())
+				for j := range elems {
+					elems[j] = v.Index(j).Interface()
+				}
+			}
+			for _, elem := range elems {
+				lit, err := bindLiteral(elem)
+				if err != nil {
+					return nil, fmt.Errorf("parameter %s: %w", p, err)
+				}
+				bound.addValue(lit, nil)
+				bound.addArg(len(bound.Values)-1, elem)
+			}
+		}
+		return &bound, nil
+	case *SetExpression:
+		if e.List == nil {
+			return e, nil
+		}
+		list, err := bindExpression(e.List, lookup)
+		if err != nil {
+			return nil, err
+		}
+		bound := *e
+		bound.List = list.(*AnyExpression)
+		return &bound, nil
+	case *QuantifierExpression:
+		predicate, err := bindExpression(e.Predicate, lookup)
+		if err != nil {
+			return nil, err
+		}
+		bound := *e
+		bound.Predicate = predicate
+		return &bound, nil
+	case *NotExpression:
+		inner, err := bindExpression(e.Expression, lookup)
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpression{Expression: inner}, nil
+	case *ConjunctionExpression:
+		children, err := bindExpressions(e.Expressions, lookup)
+		if err != nil {
+			return nil, err
+		}
+		return &ConjunctionExpression{Expressions: children}, nil
+	case *OrExpression:
+		children, err := bindExpressions(e.Expressions, lookup)
+		if err != nil {
+			return nil, err
+		}
+		return &OrExpression{Expressions: children}, nil
+	}
+	return expr, nil
+}
+
+func bindExpressions(exprs []Expression, lookup func(*parameter) (any, bool)) ([]Expression, error) {
+	bound := make([]Expression, len(exprs))
+	for i, expr := range exprs {
+		var err error
+		if bound[i], err = bindExpression(expr, lookup); err != nil {
+			return nil, err
+		}
+	}
+	return bound, nil
+}
+
+// addArg records the Go value bound to the i-th value
+func (ae *AnyExpression) addArg(i int, arg any) {
+	if arg == nil && ae.args == nil {
+		return
+	}
+	for len(ae.args) <= i {
+		ae.args = append(ae.args, nil)
+	}
+	ae.args[i] = arg
+}
+
+// isListArg reports whether a bound value is a list (any slice or array but []byte)
+func isListArg(arg any) bool {
+	if arg == nil {
+		return false
+	}
+	t := reflect.TypeOf(arg)
+	if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+		return false
+	}
+	return t.Kind() == reflect.Slice || t.Kind() == reflect.Array
+}
+
+// bindLiteral renders a bound Go value the way the same value is written in a
+// query, so it goes through the same comparisons as a literal. Durations are in
+// seconds, like humanized literals such as 10m.
+func bindLiteral(arg any) (string, error) {
+	switch v := arg.(type) {
+	case nil:
+		return "", fmt.Errorf("cannot bind nil; use IS NULL instead")
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case time.Duration:
+		return formatRat(big.NewRat(int64(v), int64(time.Second))), nil
+	case *big.Int:
+		return v.String(), nil
+	case *big.Float:
+		return v.Text('g', -1), nil
+	case *big.Rat:
+		return formatRat(v), nil
+	}
+
+	v := reflect.ValueOf(arg)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.String:
+		return v.String(), nil
+	}
+
+	if m, ok := arg.(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+	if s, ok := arg.(fmt.Stringer); ok {
+		return s.String(), nil
+	}
+	return "", fmt.Errorf("unsupported parameter type %T", arg)
+}
+
+// compareBound compares values whose bound Go type has a natural ordering that the
+// text form doesn't preserve: times (across time zones) and durations against
+// time.Duration fields. handled is false for any other combination.
+func compareBound(fieldValue reflect.Value, arg any, op TokenType) (match bool, handled bool) {
+	if arg == nil || op == CONTAINS || !fieldValue.IsValid() {
+		return false, false
+	}
+	switch a := arg.(type) {
+	case time.Time:
+		if fieldValue.Type() == timeType && fieldValue.CanInterface() {
+			return compareOrdered(fieldValue.Interface().(time.Time).Compare(a), op), true
+		}
+	case time.Duration:
+		if fieldValue.Type() == durationType {
+			d := time.Duration(fieldValue.Int())
+			switch {
+			case d < a:
+				return compareOrdered(-1, op), true
+			case d > a:
+				return compareOrdered(1, op), true
+			}
+			return compareOrdered(0, op), true
+		}
+	}
+	return false, false
+}
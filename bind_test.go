@@ -0,0 +1,167 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type bindRecord struct {
+	Name    string
+	Age     int
+	Score   float64
+	Tags    []string
+	Created time.Time
+	Timeout time.Duration
+	TTL     int // seconds
+}
+
+func TestBindParameters(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	records := []bindRecord{
+		{Name: "alice", Age: 30, Score: 0.1, Tags: []string{"go"}, Created: base, Timeout: 5 * time.Second, TTL: 60},
+		{Name: "bob", Age: 40, Score: 0.2, Tags: []string{"rust"}, Created: base.Add(time.Hour), Timeout: time.Minute, TTL: 600},
+		{Name: "x' OR Name = 'bob", Age: 50, Tags: []string{"c"}, Created: base.Add(2 * time.Hour), Timeout: time.Hour, TTL: 3600},
+	}
+	oslo := time.FixedZone("CET", 3600)
+
+	tests := []struct {
+		name  string
+		query string
+		args  []any
+		named map[string]any
+		want  []string
+	}{
+		{"Positional", "Age > ? AND Age < ?", []any{35, 45}, nil, []string{"bob"}},
+		{"Named", "Age > :min AND Name != :name", nil, map[string]any{"min": 20, "name": "alice"}, []string{"bob", "x' OR Name = 'bob"}},
+		{"Mixed", "Age >= ? AND Name = :name", []any{30}, map[string]any{"name": "alice"}, []string{"alice"}},
+		{"Named used twice", "Age = :n OR Age = :n", nil, map[string]any{"n": 40}, []string{"bob"}},
+		{"String is never parsed as query text", "Name = ?", []any{"x' OR Name = 'bob"}, nil, []string{"x' OR Name = 'bob"}},
+		{"Injection attempt matches nothing", "Name = ?", []any{"alice' OR '1' = '1"}, nil, nil},
+		{"Float", "Score = ?", []any{0.1}, nil, []string{"alice"}},
+		{"Bool and typed string", "Name = ?", []any{testName("bob")}, nil, []string{"bob"}},
+		{"Time across zones", "Created = ?", []any{time.Date(2024, 1, 1, 14, 0, 0, 0, oslo)}, nil, []string{"bob"}},
+		{"Time ordering", "Created > ?", []any{time.Date(2024, 1, 1, 13, 30, 0, 0, oslo)}, nil, []string{"bob", "x' OR Name = 'bob"}},
+		{"Duration against Duration field", "Timeout >= ?", []any{time.Minute}, nil, []string{"bob", "x' OR Name = 'bob"}},
+		{"Duration against seconds", "TTL = ?", []any{10 * time.Minute}, nil, []string{"bob"}},
+		{"List in IN", "Name IN (?)", []any{[]string{"alice", "bob"}}, nil, []string{"alice", "bob"}},
+		{"List in NOT IN", "Name NOT IN (?)", []any{[]string{"alice", "bob"}}, nil, []string{"x' OR Name = 'bob"}},
+		{"List in ANY", "ANY(Tags) = ANY(?, 'none')", []any{[]string{"rust"}}, nil, []string{"bob"}},
+		{"Empty list", "Name IN (?)", []any{[]string{}}, nil, nil},
+		{"Int list", "Age IN (?)", []any{[]int{30, 50}}, nil, []string{"alice", "x' OR Name = 'bob"}},
+		{"Literal IN", "Age IN (30, 40)", nil, nil, []string{"alice", "bob"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := Compile(tt.query)
+			if err != nil {
+				t.Fatalf("Compile(%q) returned error: %v", tt.query, err)
+			}
+			if tt.args != nil {
+				if q, err = q.Bind(tt.args...); err != nil {
+					t.Fatalf("Bind returned error: %v", err)
+				}
+			}
+			if tt.named != nil {
+				if q, err = q.BindNamed(tt.named); err != nil {
+					t.Fatalf("BindNamed returned error: %v", err)
+				}
+			}
+			results, err := Filter(q, records)
+			if err != nil {
+				t.Fatalf("Filter returned error: %v", err)
+			}
+			checkNames(t, tt.query, results, tt.want)
+		})
+	}
+}
+
+type testName string
+
+func TestListCommas(t *testing.T) {
+	records := []bindRecord{{Name: "one", Age: 1}, {Name: "two", Age: 2}, {Name: "twelve", Age: 12}, {Name: "zero"}, {Name: "thousand", Age: 1000}}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"Age IN (1,2)", []string{"one", "two"}},
+		{"Age IN (1,000,2)", []string{"one", "two", "zero"}},
+		{"Age NOT IN (1,2)", []string{"twelve", "zero", "thousand"}},
+		{"Age = 1,000", []string{"thousand"}},
+		{"Age = 1,000 OR Age IN (12,2)", []string{"two", "twelve", "thousand"}},
+		{"(Age >= 1,000)", []string{"thousand"}},
+		{"(Age > 2 AND Age < 1,000)", []string{"twelve"}},
+		{"NOT (Age < 1,000)", []string{"thousand"}},
+		{"(Age = 1,000 OR (Age IN (12,2)))", []string{"two", "twelve", "thousand"}},
+		{"Age IN (2) AND (Age < 1,000)", []string{"two"}},
+	}
+	for _, tt := range tests {
+		results, err := Parse(tt.query, records)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", tt.query, err)
+		}
+		checkNames(t, tt.query, results, tt.want)
+	}
+
+	if _, err := Parse("Age = 1,2", records); err == nil {
+		t.Errorf("expected a parse error for a comma that doesn't group thousands")
+	}
+}
+
+func TestRebind(t *testing.T) {
+	records := []bindRecord{{Name: "alice", Age: 30}, {Name: "bob", Age: 40}, {Name: "cy", Age: 50}}
+	q, err := Compile("Age = ?")
+	if err != nil {
+		t.Fatalf("Compile returned error: %v", err)
+	}
+	for _, age := range []int{30, 40, 50} {
+		bound, err := q.Bind(age)
+		if err != nil {
+			t.Fatalf("Bind returned error: %v", err)
+		}
+		results, err := Filter(bound, records)
+		if err != nil || len(results) != 1 || results[0].Age != age {
+			t.Errorf("Bind(%d) returned %v, %v", age, results, err)
+		}
+	}
+
+	// The compiled query itself stays unbound
+	if _, err := Filter(q, records); err == nil || !strings.Contains(err.Error(), "not bound") {
+		t.Errorf("expected an unbound parameter error, got %v", err)
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		bind  func(*Query) (*Query, error)
+		want  string
+	}{
+		{"Too few arguments", "Age > ? AND Age < ?", func(q *Query) (*Query, error) { return q.Bind(1) }, "2 positional parameters, got 1"},
+		{"Too many arguments", "Age > ?", func(q *Query) (*Query, error) { return q.Bind(1, 2) }, "1 positional parameters, got 2"},
+		{"Missing named", "Age > :min", func(q *Query) (*Query, error) { return q.BindNamed(map[string]any{}) }, "missing value for parameter :min"},
+		{"Unknown named", "Age > :min", func(q *Query) (*Query, error) { return q.BindNamed(map[string]any{"min": 1, "max": 2}) }, "unknown parameter :max"},
+		{"List in comparison", "Name = ?", func(q *Query) (*Query, error) { return q.Bind([]string{"a"}) }, "with a list"},
+		{"Nil", "Name = ?", func(q *Query) (*Query, error) { return q.Bind(nil) }, "use IS NULL"},
+		{"Unsupported type", "Name = ?", func(q *Query) (*Query, error) { return q.Bind(struct{}{}) }, "unsupported parameter type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := Compile(tt.query)
+			if err != nil {
+				t.Fatalf("Compile returned error: %v", err)
+			}
+			if _, err := tt.bind(q); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	user, _ := Compile("Name = ?")
+	trusted, _ := Compile("Age > 1")
+	if _, err := Restrict(user, trusted); err == nil {
+		t.Errorf("expected Restrict to reject an unbound query")
+	}
+}
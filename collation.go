@@ -0,0 +1,330 @@
+package parser
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Collation decides how string values are compared by =, !=, <, >, <=, >=,
+// CONTAINS and ANY. UPPER(), LOWER() and EXACT() in a query take precedence.
+type Collation int
+
+const (
+	// CollationDefault keeps the original behaviour: =, != and CONTAINS ignore case
+	// (by lowercasing), ordering is byte-wise and ANY is case-sensitive.
+	CollationDefault Collation = iota
+
+	// CollationBinary compares strings byte by byte for every operator.
+	CollationBinary
+
+	// CollationSimpleFold ignores case using Unicode simple case folding, so Σ, σ
+	// and ς are equal, as are K and the Kelvin sign.
+	CollationSimpleFold
+
+	// CollationFullFold also applies Unicode's multi-character folds, so "Straße"
+	// equals "STRASSE" and "ﬁle" equals "file".
+	CollationFullFold
+
+	// CollationAccentInsensitive is CollationFullFold that also ignores accents and
+	// other diacritics, so "Genève" equals "geneve" and "Łódź" equals "lodz".
+	CollationAccentInsensitive
+)
+
+// WithCollation sets the collation for every string comparison in the query.
+func WithCollation(c Collation) Option {
+	return func(o *options) {
+		o.collation = c
+	}
+}
+
+// WithFieldCollation sets the collation for one field path, overriding WithCollation.
+func WithFieldCollation(field string, c Collation) Option {
+	return func(o *options) {
+		if o.fieldCollations == nil {
+			o.fieldCollations = make(map[string]Collation)
+		}
+		o.fieldCollations[foldKey(canonicalPath(field))] = c
+	}
+}
+
+// collationFor returns the collation configured for field. ok is false if none is,
+// in which case the default behaviour applies.
+func (o *options) collationFor(field string) (c Collation, ok bool) {
+	if len(o.fieldCollations) > 0 {
+		if c, found := o.fieldCollations[foldKey(canonicalPath(field))]; found {
+			return c, c != CollationDefault
+		}
+	}
+	return o.collation, o.collation != CollationDefault
+}
+
+// scoped returns the options for the predicate of a quantifier over field, whose
+// paths are relative to field: a field collation set below field applies by its
+// path relative to field, and one set elsewhere doesn't apply
+func (o *options) scoped(field string) *options {
+	if o == nil || len(o.fieldCollations) == 0 {
+		return o
+	}
+	prefix := foldKey(canonicalPath(field)) + "."
+	scoped := *o
+	scoped.fieldCollations = make(map[string]Collation)
+	for path, c := range o.fieldCollations {
+		if rel, ok := strings.CutPrefix(path, prefix); ok {
+			scoped.fieldCollations[rel] = c
+		}
+	}
+	return &scoped
+}
+
+// key returns the form of s that is compared byte-wise under the collation, so
+// two strings are equal under c exactly when their keys are
+func (c Collation) key(s string) string {
+	switch c {
+	case CollationSimpleFold, CollationFullFold, CollationAccentInsensitive:
+	default:
+		return s
+	}
+	if isASCII(s) {
+		return strings.ToLower(s)
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if c == CollationAccentInsensitive {
+			if unicode.Is(unicode.Mn, r) {
+				continue // combining accent
+			}
+			if base, ok := accentBase[r]; ok {
+				for _, br := range base {
+					b.WriteRune(foldRune(br))
+				}
+				continue
+			}
+		}
+		if c != CollationSimpleFold {
+			if full, ok := fullFolds[r]; ok {
+				b.WriteString(full)
+				continue
+			}
+		}
+		b.WriteRune(foldRune(r))
+	}
+	return b.String()
+}
+
+// foldKey returns the simple case folding of a field name or map key. Two keys fold
+// to the same string exactly when strings.EqualFold reports them equal, so access
+// checks and lookups that both use it agree on which spellings name a field, ſ and
+// K included.
+func foldKey(s string) string {
+	if isASCII(s) {
+		return strings.ToLower(s)
+	}
+	return strings.Map(foldRune, s)
+}
+
+// foldRune maps r to one rune of its simple case folding orbit, the same for every
+// case variant of a letter: the smallest that is the lowercase of its own uppercase
+// form, so ς and the iota subscript fold to σ and ι rather than to a final or
+// combining form, or else the smallest rune in the orbit
+func foldRune(r rune) rune {
+	min, lower := r, rune(-1)
+	f := r
+	for {
+		if f < min {
+			min = f
+		}
+		if unicode.ToLower(unicode.ToUpper(f)) == f && (lower < 0 || f < lower) {
+			lower = f
+		}
+		if f = unicode.SimpleFold(f); f == r {
+			break
+		}
+	}
+	if lower >= 0 {
+		return lower
+	}
+	return min
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// fullFolds holds the multi-character case folds of Unicode's CaseFolding.txt (status
+// F): ß, the ligatures, and the Greek letters with an iota subscript or accents that
+// have no single-letter fold. The Turkic folds (status T) are not applied, so I and
+// ı stay distinct and İ folds to i with a combining dot. Results are already folded.
+var fullFolds = map[rune]string{
+	'\u00df': "ss",                 // ß
+	'\u0130': "i\u0307",            // İ
+	'\u0149': "\u02bcn",            // ŉ
+	'\u01f0': "j\u030c",            // ǰ
+	'\u0390': "\u03b9\u0308\u0301", // ΐ
+	'\u03b0': "\u03c5\u0308\u0301", // ΰ
+	'\u0587': "\u0565\u0582",       // և
+	'\u1e96': "h\u0331",            // ẖ
+	'\u1e97': "t\u0308",            // ẗ
+	'\u1e98': "w\u030a",            // ẘ
+	'\u1e99': "y\u030a",            // ẙ
+	'\u1e9a': "a\u02be",            // ẚ
+	'\u1e9e': "ss",                 // ẞ
+	'\u1f50': "\u03c5\u0313",       // ὐ
+	'\u1f52': "\u03c5\u0313\u0300", // ὒ
+	'\u1f54': "\u03c5\u0313\u0301", // ὔ
+	'\u1f56': "\u03c5\u0313\u0342", // ὖ
+	'\u1f80': "\u1f00\u03b9",       // ᾀ
+	'\u1f81': "\u1f01\u03b9",       // ᾁ
+	'\u1f82': "\u1f02\u03b9",       // ᾂ
+	'\u1f83': "\u1f03\u03b9",       // ᾃ
+	'\u1f84': "\u1f04\u03b9",       // ᾄ
+	'\u1f85': "\u1f05\u03b9",       // ᾅ
+	'\u1f86': "\u1f06\u03b9",       // ᾆ
+	'\u1f87': "\u1f07\u03b9",       // ᾇ
+	'\u1f88': "\u1f00\u03b9",       // ᾈ
+	'\u1f89': "\u1f01\u03b9",       // ᾉ
+	'\u1f8a': "\u1f02\u03b9",       // ᾊ
+	'\u1f8b': "\u1f03\u03b9",       // ᾋ
+	'\u1f8c': "\u1f04\u03b9",       // ᾌ
+	'\u1f8d': "\u1f05\u03b9",       // ᾍ
+	'\u1f8e': "\u1f06\u03b9",       // ᾎ
+	'\u1f8f': "\u1f07\u03b9",       // ᾏ
+	'\u1f90': "\u1f20\u03b9",       // ᾐ
+	'\u1f91': "\u1f21\u03b9",       // ᾑ
+	'\u1f92': "\u1f22\u03b9",       // ᾒ
+	'\u1f93': "\u1f23\u03b9",       // ᾓ
+	'\u1f94': "\u1f24\u03b9",       // ᾔ
+	'\u1f95': "\u1f25\u03b9",       // ᾕ
+	'\u1f96': "\u1f26\u03b9",       // ᾖ
+	'\u1f97': "\u1f27\u03b9",       // ᾗ
+	'\u1f98': "\u1f20\u03b9",       // ᾘ
+	'\u1f99': "\u1f21\u03b9",       // ᾙ
+	'\u1f9a': "\u1f22\u03b9",       // ᾚ
+	'\u1f9b': "\u1f23\u03b9",       // ᾛ
+	'\u1f9c': "\u1f24\u03b9",       // ᾜ
+	'\u1f9d': "\u1f25\u03b9",       // ᾝ
+	'\u1f9e': "\u1f26\u03b9",       // ᾞ
+	'\u1f9f': "\u1f27\u03b9",       // ᾟ
+	'\u1fa0': "\u1f60\u03b9",       // ᾠ
+	'\u1fa1': "\u1f61\u03b9",       // ᾡ
+	'\u1fa2': "\u1f62\u03b9",       // ᾢ
+	'\u1fa3': "\u1f63\u03b9",       // ᾣ
+	'\u1fa4': "\u1f64\u03b9",       // ᾤ
+	'\u1fa5': "\u1f65\u03b9",       // ᾥ
+	'\u1fa6': "\u1f66\u03b9",       // ᾦ
+	'\u1fa7': "\u1f67\u03b9",       // ᾧ
+	'\u1fa8': "\u1f60\u03b9",       // ᾨ
+	'\u1fa9': "\u1f61\u03b9",       // ᾩ
+	'\u1faa': "\u1f62\u03b9",       // ᾪ
+	'\u1fab': "\u1f63\u03b9",       // ᾫ
+	'\u1fac': "\u1f64\u03b9",       // ᾬ
+	'\u1fad': "\u1f65\u03b9",       // ᾭ
+	'\u1fae': "\u1f66\u03b9",       // ᾮ
+	'\u1faf': "\u1f67\u03b9",       // ᾯ
+	'\u1fb2': "\u1f70\u03b9",       // ᾲ
+	'\u1fb3': "\u03b1\u03b9",       // ᾳ
+	'\u1fb4': "\u03ac\u03b9",       // ᾴ
+	'\u1fb6': "\u03b1\u0342",       // ᾶ
+	'\u1fb7': "\u03b1\u0342\u03b9", // ᾷ
+	'\u1fbc': "\u03b1\u03b9",       // ᾼ
+	'\u1fc2': "\u1f74\u03b9",       // ῂ
+	'\u1fc3': "\u03b7\u03b9",       // ῃ
+	'\u1fc4': "\u03ae\u03b9",       // ῄ
+	'\u1fc6': "\u03b7\u0342",       // ῆ
+	'\u1fc7': "\u03b7\u0342\u03b9", // ῇ
+	'\u1fcc': "\u03b7\u03b9",       // ῌ
+	'\u1fd2': "\u03b9\u0308\u0300", // ῒ
+	'\u1fd3': "\u03b9\u0308\u0301", // ΐ
+	'\u1fd6': "\u03b9\u0342",       // ῖ
+	'\u1fd7': "\u03b9\u0308\u0342", // ῗ
+	'\u1fe2': "\u03c5\u0308\u0300", // ῢ
+	'\u1fe3': "\u03c5\u0308\u0301", // ΰ
+	'\u1fe4': "\u03c1\u0313",       // ῤ
+	'\u1fe6': "\u03c5\u0342",       // ῦ
+	'\u1fe7': "\u03c5\u0308\u0342", // ῧ
+	'\u1ff2': "\u1f7c\u03b9",       // ῲ
+	'\u1ff3': "\u03c9\u03b9",       // ῳ
+	'\u1ff4': "\u03ce\u03b9",       // ῴ
+	'\u1ff6': "\u03c9\u0342",       // ῶ
+	'\u1ff7': "\u03c9\u0342\u03b9", // ῷ
+	'\u1ffc': "\u03c9\u03b9",       // ῼ
+	'\ufb00': "ff",                 // ﬀ
+	'\ufb01': "fi",                 // ﬁ
+	'\ufb02': "fl",                 // ﬂ
+	'\ufb03': "ffi",                // ﬃ
+	'\ufb04': "ffl",                // ﬄ
+	'\ufb05': "st",                 // ﬅ
+	'\ufb06': "st",                 // ﬆ
+	'\ufb13': "\u0574\u0576",       // ﬓ
+	'\ufb14': "\u0574\u0565",       // ﬔ
+	'\ufb15': "\u0574\u056b",       // ﬕ
+	'\ufb16': "\u057e\u0576",       // ﬖ
+	'\ufb17': "\u0574\u056d",       // ﬗ
+}
+
+// accentBase maps precomposed Latin letters to their letters without diacritics.
+// Decomposed input is handled by dropping combining marks.
+var accentBase = func() map[rune]string {
+	groups := map[string]string{
+		"a":  "àáâãäåāăąǎǟǡǻȁȃȧḁạảấầẩẫậắằẳẵặ",
+		"A":  "ÀÁÂÃÄÅĀĂĄǍǞǠǺȀȂȦḀẠẢẤẦẨẪẬẮẰẲẴẶ",
+		"c":  "çćĉċčḉ",
+		"C":  "ÇĆĈĊČḈ",
+		"d":  "ďđḋḍḏḑḓ",
+		"D":  "ĎĐḊḌḎḐḒ",
+		"e":  "èéêëēĕėęěȅȇȩḕḗḙḛḝẹẻẽếềểễệ",
+		"E":  "ÈÉÊËĒĔĖĘĚȄȆȨḔḖḘḚḜẸẺẼẾỀỂỄỆ",
+		"g":  "ĝğġģǧǵḡ",
+		"G":  "ĜĞĠĢǦǴḠ",
+		"h":  "ĥħȟḣḥḧḩḫẖ",
+		"H":  "ĤĦȞḢḤḦḨḪ",
+		"i":  "ìíîïĩīĭįıǐȉȋḭḯỉị",
+		"I":  "ÌÍÎÏĨĪĬĮİǏȈȊḬḮỈỊ",
+		"j":  "ĵǰ",
+		"J":  "Ĵ",
+		"k":  "ķǩḱḳḵ",
+		"K":  "ĶǨḰḲḴ",
+		"l":  "ĺļľŀłḷḹḻḽ",
+		"L":  "ĹĻĽĿŁḶḸḺḼ",
+		"n":  "ñńņňǹṅṇṉṋ",
+		"N":  "ÑŃŅŇǸṄṆṈṊ",
+		"o":  "òóôõöøōŏőơǒǫǭǿȍȏȫȭȯȱṍṏṑṓọỏốồổỗộớờởỡợ",
+		"O":  "ÒÓÔÕÖØŌŎŐƠǑǪǬǾȌȎȪȬȮȰṌṎṐṒỌỎỐỒỔỖỘỚỜỞỠỢ",
+		"r":  "ŕŗřȑȓṙṛṝṟ",
+		"R":  "ŔŖŘȐȒṘṚṜṞ",
+		"s":  "śŝşšșṡṣṥṧṩ",
+		"S":  "ŚŜŞŠȘṠṢṤṦṨ",
+		"t":  "ţťŧțṫṭṯṱẗ",
+		"T":  "ŢŤŦȚṪṬṮṰ",
+		"u":  "ùúûüũūŭůűųưǔǖǘǚǜȕȗṳṵṷṹṻụủứừửữự",
+		"U":  "ÙÚÛÜŨŪŬŮŰŲƯǓǕǗǙǛȔȖṲṴṶṸṺỤỦỨỪỬỮỰ",
+		"w":  "ŵẁẃẅẇẉẘ",
+		"W":  "ŴẀẂẄẆẈ",
+		"y":  "ýÿŷẏẙỳỵỷỹ",
+		"Y":  "ÝŸŶẎỲỴỶỸ",
+		"z":  "źżžẑẓẕ",
+		"Z":  "ŹŻŽẐẒẔ",
+		"ae": "æǣǽ",
+		"AE": "ÆǢǼ",
+		"oe": "œ",
+		"OE": "Œ",
+		"th": "þ",
+		"TH": "Þ",
+		"dh": "ð",
+		"DH": "Ð",
+	}
+	m := make(map[rune]string)
+	for base, letters := range groups {
+		for _, r := range letters {
+			m[r] = base
+		}
+	}
+	return m
+}()
@@ -0,0 +1,120 @@
+package parser
+
+import "testing"
+
+func TestCollationKeys(t *testing.T) {
+	tests := []struct {
+		c    Collation
+		a, b string
+		same bool
+	}{
+		{CollationBinary, "Go", "go", false},
+		{CollationSimpleFold, "Go", "GO", true},
+		{CollationSimpleFold, "ΣΊΣΥΦΟΣ", "σίσυφος", true},
+		{CollationSimpleFold, "σίσυφος", "σίσυφοσ", true}, // final sigma
+		{CollationSimpleFold, "K", "k", true},             // Kelvin sign
+		{CollationSimpleFold, "Straße", "STRASSE", false},
+		{CollationFullFold, "Straße", "STRASSE", true},
+		{CollationFullFold, "STRAẞE", "strasse", true},
+		{CollationFullFold, "ﬁle", "FILE", true},
+		{CollationFullFold, "Genève", "geneve", false},
+		{CollationFullFold, "\u0390", "\u1fd3", true}, // ΐ in two blocks
+		{CollationFullFold, "ᾳ", "ΑΙ", true},          // iota subscript
+		{CollationFullFold, "ᾈ", "ἀι", true},          // iota adscript
+		{CollationFullFold, "ῷ", "ῶι", true},
+		{CollationFullFold, "ΣΟΦΟΣ", "σοφος", true},
+		{CollationFullFold, "ı", "I", false}, // no Turkic folds
+		{CollationFullFold, "İ", "i\u0307", true},
+		{CollationAccentInsensitive, "Genève", "GENEVE", true},
+		{CollationAccentInsensitive, "Łódź", "lodz", true},
+		{CollationAccentInsensitive, "Ærø", "aero", true},
+		{CollationAccentInsensitive, "Ünïcode", "unicode", true},
+		{CollationAccentInsensitive, "Cafe\u0301", "café", true}, // decomposed accent
+		{CollationAccentInsensitive, "İstanbul", "istanbul", true},
+		{CollationAccentInsensitive, "Straße", "strasse", true},
+	}
+	for _, tt := range tests {
+		if got := tt.c.key(tt.a) == tt.c.key(tt.b); got != tt.same {
+			t.Errorf("collation %d: %q vs %q equal = %v, want %v (keys %q, %q)", tt.c, tt.a, tt.b, got, tt.same, tt.c.key(tt.a), tt.c.key(tt.b))
+		}
+	}
+}
+
+func TestCollationOptions(t *testing.T) {
+	type Place struct {
+		Name    string
+		City    string
+		Country string
+		Aliases []string
+	}
+	places := []Place{
+		{Name: "Straße 1", City: "Genève", Country: "CH", Aliases: []string{"Genf"}},
+		{Name: "strasse 2", City: "Zürich", Country: "ch", Aliases: []string{"Zurigo"}},
+		{Name: "Main St", City: "Oslo", Country: "NO", Aliases: []string{"Christiania"}},
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		opts  []Option
+		want  []string
+	}{
+		{"Default lowercases =", "Country = 'ch'", nil, []string{"Straße 1", "strasse 2"}},
+		{"Default ß is not ss", "Name CONTAINS 'STRASSE'", nil, []string{"strasse 2"}},
+		{"Default ordering is byte-wise", "Name > 'a'", nil, []string{"strasse 2"}},
+		{"Default ANY is case-sensitive", "ANY(Country) = ANY('ch')", nil, []string{"strasse 2"}},
+		{"Binary =", "Country = 'ch'", []Option{WithCollation(CollationBinary)}, []string{"strasse 2"}},
+		{"Full fold CONTAINS", "Name CONTAINS 'STRASSE'", []Option{WithCollation(CollationFullFold)}, []string{"Straße 1", "strasse 2"}},
+		{"Full fold ordering", "Name > 'a'", []Option{WithCollation(CollationSimpleFold)}, []string{"Straße 1", "strasse 2", "Main St"}},
+		{"Full fold !=", "Name != 'STRASSE 2'", []Option{WithCollation(CollationFullFold)}, []string{"Straße 1", "Main St"}},
+		{"Accent-insensitive =", "City = 'geneve'", []Option{WithCollation(CollationAccentInsensitive)}, []string{"Straße 1"}},
+		{"Accent-insensitive ANY", "ANY(City) = ANY('ZURICH', 'oslo')", []Option{WithCollation(CollationAccentInsensitive)}, []string{"strasse 2", "Main St"}},
+		{"Accent-insensitive IN", "City IN ('zurich')", []Option{WithCollation(CollationAccentInsensitive)}, []string{"strasse 2"}},
+		{"Field collation", "City = 'zurich' OR Country = 'no'", []Option{WithFieldCollation("City", CollationAccentInsensitive)}, []string{"strasse 2", "Main St"}},
+		{"Field collation overrides query", "Country = 'ch'", []Option{WithCollation(CollationSimpleFold), WithFieldCollation("country", CollationBinary)}, []string{"strasse 2"}},
+		{"EXACT overrides collation", "EXACT(Country) = 'ch'", []Option{WithCollation(CollationSimpleFold)}, []string{"strasse 2"}},
+		{"String slice elements", "Aliases = 'GENF'", []Option{WithCollation(CollationSimpleFold)}, []string{"Straße 1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := Parse(tt.query, places, tt.opts...)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.query, err)
+			}
+			checkNames(t, tt.query, results, tt.want)
+		})
+	}
+}
+
+func TestFieldCollationInQuantifier(t *testing.T) {
+	type Office struct{ City string }
+	type Company struct {
+		Name    string
+		City    string
+		Offices []Office
+	}
+	companies := []Company{
+		{Name: "a", City: "Genève", Offices: []Office{{City: "Zürich"}}},
+		{Name: "b", City: "Oslo", Offices: []Office{{City: "Genève"}, {City: "Oslo"}}},
+	}
+
+	tests := []struct {
+		query string
+		opts  []Option
+		want  []string
+	}{
+		{"Offices ANY (City = 'zurich')", []Option{WithFieldCollation("Offices.City", CollationAccentInsensitive)}, []string{"a"}},
+		{"Offices ALL (City != 'GENEVE')", []Option{WithFieldCollation("offices.city", CollationAccentInsensitive)}, []string{"a"}},
+		{"Offices ANY (City = 'geneve')", []Option{WithFieldCollation("City", CollationAccentInsensitive)}, nil},
+		{"City = 'geneve' AND Offices ANY (City = 'zurich')", []Option{WithFieldCollation("City", CollationAccentInsensitive)}, nil},
+		{"Offices ANY (City = 'zurich')", []Option{WithCollation(CollationAccentInsensitive), WithFieldCollation("Offices.City", CollationBinary)}, nil},
+	}
+	for _, tt := range tests {
+		results, err := Parse(tt.query, companies, tt.opts...)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", tt.query, err)
+		}
+		checkNames(t, tt.query, results, tt.want)
+	}
+}
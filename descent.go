@@ -0,0 +1,100 @@
+package parser
+
+import "reflect"
+
+// visitKey identifies a pointer, map or slice already visited during recursive
+// descent. Slices also need their length, as a slice and a prefix of it share a
+// pointer.
+type visitKey struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// descend applies a recursive ..name or ..* segment: it walks val and everything
+// below it, through struct fields, map values and slice elements, and returns what
+// the segment matches in each struct and map found. Pointers, maps and slices are
+// visited once, so self-referential structures end.
+func descend(val reflect.Value, seg pathSegment, opts *options) ([]reflect.Value, error) {
+	seg.recursive = false
+	visited := map[visitKey]bool{}
+	var found []reflect.Value
+
+	var walk func(v reflect.Value) error
+	walk = func(v reflect.Value) error {
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return nil
+			}
+			if v.Kind() == reflect.Ptr {
+				key := visitKey{v.Pointer(), v.Type(), 0}
+				if visited[key] {
+					return nil
+				}
+				visited[key] = true
+			}
+			v = v.Elem()
+		}
+
+		switch v.Kind() {
+		case reflect.Map:
+			key := visitKey{v.Pointer(), v.Type(), 0}
+			if v.IsNil() || visited[key] {
+				return nil
+			}
+			visited[key] = true
+			if err := matchSegment(v, seg, opts, &found); err != nil {
+				return err
+			}
+			for _, k := range sortedMapKeys(v) {
+				if err := walk(v.MapIndex(k)); err != nil {
+					return err
+				}
+			}
+		case reflect.Struct:
+			if err := matchSegment(v, seg, opts, &found); err != nil {
+				return err
+			}
+			for _, f := range cachedStructFields(v.Type(), opts.orDefault()).all {
+				if err := walk(f.get(v)); err != nil {
+					return err
+				}
+			}
+		case reflect.Slice:
+			key := visitKey{v.Pointer(), v.Type(), v.Len()}
+			if v.IsNil() || visited[key] {
+				return nil
+			}
+			visited[key] = true
+			fallthrough
+		case reflect.Array:
+			for i := 0; i < v.Len(); i++ {
+				if err := walk(v.Index(i)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := walk(val); err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// matchSegment appends what a name or * segment matches in a struct or map
+func matchSegment(v reflect.Value, seg pathSegment, opts *options, found *[]reflect.Value) error {
+	if seg.wildcard {
+		*found = append(*found, expandSegment(v, seg, opts)...)
+		return nil
+	}
+	value, err := getFieldByNameCaseInsensitive(v, seg.name, opts)
+	if err != nil {
+		return err
+	}
+	if value.IsValid() {
+		*found = append(*found, value)
+	}
+	return nil
+}
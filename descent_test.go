@@ -0,0 +1,159 @@
+package parser
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type descentContainer struct {
+	Name  string
+	Image string
+}
+
+type descentSpec struct {
+	Containers []descentContainer
+	Init       *descentContainer
+}
+
+type descentNode struct {
+	Name     string
+	Spec     descentSpec
+	Labels   map[string]any
+	Children []*descentNode
+	Parent   *descentNode
+	Password string
+}
+
+func TestRecursiveDescent(t *testing.T) {
+	a := &descentNode{
+		Name: "a",
+		Spec: descentSpec{Containers: []descentContainer{{Name: "web", Image: "nginx"}, {Name: "db", Image: "postgres"}}},
+		Labels: map[string]any{
+			"team": "platform",
+			"meta": map[string]any{"owner": map[string]any{"team": "core"}},
+		},
+	}
+	b := &descentNode{
+		Name:   "b",
+		Spec:   descentSpec{Containers: []descentContainer{{Name: "proxy", Image: "envoy"}}, Init: &descentContainer{Name: "init", Image: "busybox"}},
+		Labels: map[string]any{"team": "edge"},
+	}
+	child := &descentNode{Name: "c", Parent: b, Spec: descentSpec{Containers: []descentContainer{{Image: "redis"}}}}
+	b.Children = []*descentNode{child}
+	child.Children = []*descentNode{b} // a cycle through Children and Parent
+	nodes := []*descentNode{a, b}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"Any depth", "..Image = 'postgres'", []string{"a"}},
+		{"Below a field", "Spec..Image = 'busybox'", []string{"b"}},
+		{"Through pointers and cycles", "..Image = 'redis'", []string{"b"}},
+		{"Through maps", "Labels..team = 'core'", []string{"a"}},
+		{"Map at top level", "Labels..team = 'edge'", []string{"b"}},
+		{"Recursive wildcard", "Labels..* = 'core'", []string{"a"}},
+		{"Nothing found is NULL", "Spec..Missing IS NULL", []string{"a", "b"}},
+		{"Nothing found never equals", "Spec..Missing = 'x'", nil},
+		{"Set operators", "Spec..Image CONTAINS ALL ('nginx', 'postgres')", []string{"a"}},
+		{"JSONPath", "$.Spec.Containers[*].Image = 'nginx'", []string{"a"}},
+		{"JSONPath index", "$.Spec.Containers[1].Name = 'db'", []string{"a"}},
+		{"JSONPath wildcard", "$.Spec.Containers[*].Image = 'envoy'", []string{"b"}},
+		{"JSONPath wildcard picks elements", "$.Spec.Containers[*].Image = 'proxy'", nil},
+		{"JSONPath recursive", "$..Image = 'busybox'", []string{"b"}},
+		{"JSONPath bracket keys", "$['Labels']['team'] = 'edge'", []string{"b"}},
+		{"In quantifier", "Spec.Containers ANY (..Image = 'nginx')", []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := Parse(tt.query, nodes)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.query, err)
+			}
+			checkNames(t, tt.query, results, tt.want)
+		})
+	}
+}
+
+func TestRecursivePathSegments(t *testing.T) {
+	tests := []struct {
+		path  string
+		names []string
+	}{
+		{"..Name", []string{"**", "Name"}},
+		{"Spec..Image", []string{"Spec", "**", "Image"}},
+		{"$.spec.containers[*].image", []string{"spec", "containers", elementsName, "image"}},
+		{"$..name", []string{"**", "name"}},
+		{"$['a'].b", []string{"a", "b"}},
+		{"a..*", []string{"a", "**", "*"}},
+	}
+	for _, tt := range tests {
+		if got := splitPath(tt.path); !reflect.DeepEqual(got, tt.names) {
+			t.Errorf("splitPath(%q) = %q, want %q", tt.path, got, tt.names)
+		}
+	}
+}
+
+func TestRecursiveAccessControl(t *testing.T) {
+	tests := []struct {
+		query string
+		opts  []Option
+		ok    bool
+	}{
+		{"..Password = 'x'", []Option{WithDeniedFields("Children.Password")}, false},
+		{"..Password = 'x'", []Option{WithDeniedFields("Password")}, false},
+		{"Spec..Image = 'x'", []Option{WithDeniedFields("Password")}, true},
+		{"Spec..Image = 'x'", []Option{WithDeniedFields("Spec.*")}, false},
+		{"..Name = 'x'", []Option{WithDeniedFields("Labels.*")}, false},
+		{"Spec..Image = 'x'", []Option{WithAllowedFields("Spec.*")}, true},
+		{"Spec..Image = 'x'", []Option{WithAllowedFields("Spec.Init.Image")}, false},
+		{"..Name = 'x'", []Option{WithAllowedFields("*")}, true},
+		{"$.Spec.Containers[0].Image = 'x'", []Option{WithAllowedFields("Spec.Containers.Image")}, true},
+		{"$.Spec.Containers[*].Image = 'x'", []Option{WithAllowedFields("Spec.Containers.Image")}, false},
+		{"$.Spec.Containers[*].Image = 'x'", []Option{WithAllowedFields("Spec.Containers.*")}, true},
+		{"$.Spec.*.Image = 'x'", []Option{WithAllowedFields("Spec.Containers.Image")}, false},
+	}
+	nodes := []*descentNode{{Name: "a"}}
+	for _, tt := range tests {
+		_, err := Parse(tt.query, nodes, tt.opts...)
+		if tt.ok && err != nil {
+			t.Errorf("Parse(%q) returned error: %v", tt.query, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrFieldNotAllowed) {
+			t.Errorf("Parse(%q) expected ErrFieldNotAllowed, got %v", tt.query, err)
+		}
+	}
+}
+
+func TestElementsAccessControl(t *testing.T) {
+	type item struct{ Secret, Name string }
+	type record struct {
+		Name  string
+		Ins   []item
+		Items []any
+		Tags  map[string]string
+	}
+	records := []record{{
+		Name:  "a",
+		Ins:   []item{{Secret: "s"}},
+		Items: []any{map[string]any{"Secret": "s"}},
+		Tags:  map[string]string{"secret": "s"},
+	}}
+	deny := WithDeniedFields("Ins.Secret", "Items.Secret", "Tags.secret")
+
+	// [*] selects a slice's elements without using up a name, and on a map reads
+	// every key, so each of these could reach a denied field
+	for _, query := range []string{"Ins[*].Secret = 's'", "Items[*].Secret = 's'", "$.Ins[*].Secret = 's'", "Tags[*] = 's'"} {
+		if results, err := Parse(query, records, deny); !errors.Is(err, ErrFieldNotAllowed) {
+			t.Errorf("Parse(%q) = %d results, %v, want ErrFieldNotAllowed", query, len(results), err)
+		}
+	}
+
+	// Other fields of the elements stay readable
+	results, err := Parse("Ins[*].Name = ''", records, deny)
+	if err != nil || len(results) != 1 {
+		t.Errorf("Parse returned %d results, %v", len(results), err)
+	}
+}
@@ -1,13 +1,26 @@
 package parser
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
 
-// An enhanced lexer that supports negative numbers
+// An enhanced lexer that supports negative numbers. It reads the input as UTF-8, so
+// identifiers and map keys may contain any Unicode letter.
 type EnhancedLexer struct {
 	input        string
-	position     int
-	readPosition int
-	ch           byte
+	position     int  // byte offset of ch
+	readPosition int  // byte offset of the character after ch
+	ch           rune // 0 at the end of the input
+	column       int  // column of ch in runes, starting at 1
+	lists        valueLists
+
+	// original and offsets map positions in input back to the query as typed,
+	// before normalizeQuery expanded units in it
+	original string
+	offsets  []int
 }
 
 // NewEnhancedLexer creates a new enhanced lexer that supports negative numbers
@@ -17,35 +30,77 @@ func NewEnhancedLexer(input string) *EnhancedLexer {
 	return l
 }
 
+// newQueryLexer lexes query after normalizeQuery, reporting columns in query as typed
+func newQueryLexer(query string, units *UnitRegistry) *EnhancedLexer {
+	normalized, offsets := normalizeQuery(query, units)
+	l := NewEnhancedLexer(normalized)
+	l.original, l.offsets = query, offsets
+	return l
+}
+
 func (l *EnhancedLexer) readChar() {
+	if l.readPosition <= len(l.input) {
+		l.column++
+	}
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
-	} else {
-		l.ch = l.input[l.readPosition]
+		l.position = len(l.input)
+		l.readPosition = len(l.input) + 1
+		return
 	}
+	r, width := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	l.ch = r
 	l.position = l.readPosition
-	l.readPosition++
+	l.readPosition += width
 }
 
-func (l *EnhancedLexer) peekChar() byte {
+// skipTo advances to the character at byte offset end
+func (l *EnhancedLexer) skipTo(end int) {
+	for l.position < end && l.position < len(l.input) {
+		l.readChar()
+	}
+}
+
+func (l *EnhancedLexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
 	}
-	return l.input[l.readPosition]
+	r, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	return r
 }
 
 // peekTwoChars returns the character two positions ahead
-func peekTwoChars(l *EnhancedLexer) byte {
-	if l.readPosition+1 >= len(l.input) {
+func peekTwoChars(l *EnhancedLexer) rune {
+	if l.readPosition >= len(l.input) {
 		return 0
 	}
-	return l.input[l.readPosition+1]
+	_, width := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	if l.readPosition+width >= len(l.input) {
+		return 0
+	}
+	r, _ := utf8.DecodeRuneInString(l.input[l.readPosition+width:])
+	return r
 }
 
+// NextToken returns the next token. Tokens carry the column they start at, and
+// ILLEGAL tokens describe the problem and where it is.
 func (l *EnhancedLexer) NextToken() Token {
-	var tok Token
-
 	l.skipWhitespace()
+	column := l.column
+	if l.offsets != nil {
+		column = utf8.RuneCountInString(l.original[:l.offsets[min(l.position, len(l.input))]]) + 1
+	}
+	tok := l.nextToken()
+	l.lists.add(tok.Type)
+	tok.Column = column
+	if tok.Type == ILLEGAL {
+		tok.Literal = fmt.Sprintf("%s (column %d)", tok.Literal, column)
+	}
+	return tok
+}
+
+func (l *EnhancedLexer) nextToken() Token {
+	var tok Token
 
 	switch l.ch {
 	case '=':
@@ -57,7 +112,7 @@ func (l *EnhancedLexer) NextToken() Token {
 			literal := string(ch) + string(l.ch)
 			tok = Token{Type: NE, Literal: literal}
 		} else {
-			tok = newToken(ILLEGAL, l.ch)
+			tok = illegalChar(l.ch)
 		}
 	case '<':
 		if l.peekChar() == '=' {
@@ -77,54 +132,68 @@ func (l *EnhancedLexer) NextToken() Token {
 		} else {
 			tok = newToken(GT, l.ch)
 		}
+	case '?':
+		tok = newToken(PARAM, l.ch)
+	case ':':
+		// Named parameter, e.g. :name
+		if isLetterRune(l.peekChar()) {
+			l.readChar()
+			position := l.position
+			for isLetterRune(l.ch) || isDigitRune(l.ch) {
+				l.readChar()
+			}
+			return Token{Type: PARAM, Literal: ":" + l.input[position:l.position]}
+		}
+		tok = illegalChar(l.ch)
 	case '(':
 		tok = newToken(LPAREN, l.ch)
 	case ')':
 		tok = newToken(RPAREN, l.ch)
+	case '[':
+		tok = newToken(LBRACKET, l.ch)
+	case ']':
+		tok = newToken(RBRACKET, l.ch)
 	case ',':
-		// Check if this comma is between digits, which would make it part of a number
-		if isDigit(l.peekChar()) && l.position > 0 && isDigit(l.input[l.position-1]) {
-			tok.Type = NUMBER
-			tok.Literal = l.readNumber()
-			// Include the comma in the literal
-			tok.Literal = "," + tok.Literal
-			return tok
-		} else {
-			tok = newToken(COMMA, l.ch)
+		tok = newToken(COMMA, l.ch)
+	case '\'', '"':
+		value, end, err := scanString(l.input, l.position)
+		l.skipTo(end)
+		if err != nil {
+			return Token{Type: ILLEGAL, Literal: err.Error()}
 		}
-	case '\'':
-		tok.Type = STRING
-		tok.Literal = l.readString()
-		// Check for unclosed string marker
-		if strings.HasPrefix(tok.Literal, "_UNCLOSED_STRING_") {
-			tok.Type = ILLEGAL
-			tok.Literal = "unclosed string: " + strings.TrimPrefix(tok.Literal, "_UNCLOSED_STRING_")
-		}
-		return tok
+		return Token{Type: STRING, Literal: value}
 	case '-':
 		// Check if it's a negative number
-		if isDigit(l.peekChar()) {
+		if isDigitRune(l.peekChar()) {
 			tok.Type = NUMBER
 			tok.Literal = l.readNumber()
 			return tok
 		} else {
-			tok = newToken(ILLEGAL, l.ch)
+			tok = illegalChar(l.ch)
 		}
 	case 0: // EOF
 		tok.Literal = ""
 		tok.Type = EOF
 	default:
-		if isLetter(l.ch) {
-			tok.Literal = l.readIdentifier()
-			typeFromIdent := LookupIdentifier(tok.Literal)
-			tok.Type = typeFromIdent
+		// Paths may also start with a quoted segment, $ (JSONPath) or .. (recursive)
+		if isLetterRune(l.ch) || l.ch == '`' || l.ch == '$' || l.ch == '.' && l.peekChar() == '.' {
+			literal, err := l.readIdentifier()
+			if err != nil {
+				return Token{Type: ILLEGAL, Literal: err.Error()}
+			}
+			tok.Literal = literal
+			tok.Type = IDENTIFIER
+			// Quoted identifiers are never keywords, so `Not` names a field
+			if !strings.ContainsAny(literal, "`[") {
+				tok.Type = LookupIdentifier(literal)
+			}
 			return tok
-		} else if isDigit(l.ch) {
+		} else if isDigitRune(l.ch) {
 			tok.Type = NUMBER
 			tok.Literal = l.readNumber()
 			return tok
 		} else {
-			tok = newToken(ILLEGAL, l.ch)
+			tok = illegalChar(l.ch)
 		}
 	}
 
@@ -138,12 +207,34 @@ func (l *EnhancedLexer) skipWhitespace() {
 	}
 }
 
-func (l *EnhancedLexer) readIdentifier() string {
+// readIdentifier reads a field path. Besides letters, digits, dots and underscores it
+// may contain backtick-quoted segments, bracketed segments such as ['team name'] and
+// * wildcard segments.
+func (l *EnhancedLexer) readIdentifier() (string, error) {
 	position := l.position
-	for isLetter(l.ch) || isDigit(l.ch) || l.ch == '.' || l.ch == '_' {
-		l.readChar()
+	for {
+		var end int
+		var err error
+		switch {
+		case isLetterRune(l.ch) || isDigitRune(l.ch) || l.ch == '.' || l.ch == '_' || l.ch == '$' && l.position == position:
+			l.readChar()
+			continue
+		case l.ch == '*' && l.position > position && l.input[l.position-1] == '.':
+			// A wildcard segment, as in Metadata.*.level
+			l.readChar()
+			continue
+		case l.ch == '`':
+			_, end, err = scanBacktick(l.input, l.position)
+		case l.ch == '[' && l.position > position:
+			end, err = scanBracket(l.input, l.position)
+		default:
+			return l.input[position:l.position], nil
+		}
+		l.skipTo(end)
+		if err != nil {
+			return "", err
+		}
 	}
-	return l.input[position:l.position]
 }
 
 func (l *EnhancedLexer) readNumber() string {
@@ -153,10 +244,11 @@ func (l *EnhancedLexer) readNumber() string {
 		l.readChar()
 	}
 
-	// Read integer part, allowing commas for readability (e.g., 1,000,000)
+	// Read integer part, allowing commas for readability (e.g., 1,000,000). Inside a
+	// list, or without three digits after it, a comma separates values, as in (1,2)
 	hasDigits := false
-	for isDigit(l.ch) || l.ch == ',' {
-		if isDigit(l.ch) {
+	for isDigitRune(l.ch) || (l.ch == ',' && !l.lists.inList() && isThousandsComma(l.input, l.position)) {
+		if isDigitRune(l.ch) {
 			hasDigits = true
 		}
 		l.readChar()
@@ -168,16 +260,16 @@ func (l *EnhancedLexer) readNumber() string {
 	}
 
 	// Read decimal part if present
-	if l.ch == '.' && isDigit(l.peekChar()) {
+	if l.ch == '.' && isDigitRune(l.peekChar()) {
 		l.readChar() // Read '.'
-		for isDigit(l.ch) {
+		for isDigitRune(l.ch) {
 			l.readChar()
 		}
 	}
 
 	// Read scientific notation if present (e.g., 1.23e45, 1e10)
-	if (l.ch == 'e' || l.ch == 'E') && (isDigit(l.peekChar()) ||
-		((l.peekChar() == '+' || l.peekChar() == '-') && isDigit(peekTwoChars(l)))) {
+	if (l.ch == 'e' || l.ch == 'E') && (isDigitRune(l.peekChar()) ||
+		((l.peekChar() == '+' || l.peekChar() == '-') && isDigitRune(peekTwoChars(l)))) {
 
 		l.readChar() // Read 'e' or 'E'
 
@@ -187,7 +279,7 @@ func (l *EnhancedLexer) readNumber() string {
 		}
 
 		// Read exponent
-		for isDigit(l.ch) {
+		for isDigitRune(l.ch) {
 			l.readChar()
 		}
 	}
@@ -195,27 +287,58 @@ func (l *EnhancedLexer) readNumber() string {
 	return l.input[position:l.position]
 }
 
-func (l *EnhancedLexer) readString() string {
-	position := l.position + 1
-	for {
-		l.readChar()
-		if l.ch == '\'' || l.ch == 0 {
-			break
-		}
-		// Handle escape sequences
-		if l.ch == '\\' && l.peekChar() == '\'' {
-			l.readChar() // Skip the backslash and include the quote
+// valueLists tracks the open parentheses and brackets of a query and which of them
+// hold a list of values, where a comma separates values rather than grouping the
+// digits of a number. Parentheses that group expressions, including the predicate
+// of a quantifier, don't.
+type valueLists struct {
+	open        []bool
+	prev, prev2 TokenType // the last two tokens
+}
+
+// add records the next token of the query
+func (v *valueLists) add(t TokenType) {
+	switch t {
+	case LPAREN:
+		v.open = append(v.open, v.opensList())
+	case LBRACKET:
+		v.open = append(v.open, true)
+	case RPAREN, RBRACKET:
+		if len(v.open) > 0 {
+			v.open = v.open[:len(v.open)-1]
 		}
 	}
-	literal := l.input[position:l.position]
-	// Check if the string was unclosed (ended with EOF)
-	isUnclosed := l.ch == 0
-	if l.ch != 0 { // Only advance if we're not at EOF
-		l.readChar()
-	}
-	if isUnclosed {
-		// Return a special marker that can be checked by the token consumer
-		return "_UNCLOSED_STRING_" + literal
+	v.prev, v.prev2 = t, v.prev
+}
+
+// opensList reports whether a parenthesis after the tokens so far opens a list:
+// after IN, OVERLAPS, CONTAINS ONLY, = or !=, or after ANY or ALL unless they
+// quantify a field, as in Lines ANY (Qty > 1,000)
+func (v *valueLists) opensList() bool {
+	switch v.prev {
+	case IN, OVERLAPS, ONLY, EQ, NE:
+		return true
+	case ANY, ALL:
+		return v.prev2 != IDENTIFIER
 	}
-	return literal
+	return false
+}
+
+// inList reports whether the innermost open parenthesis or bracket holds a list
+func (v *valueLists) inList() bool {
+	return len(v.open) > 0 && v.open[len(v.open)-1]
+}
+
+// illegalChar returns the ILLEGAL token for a character that can't start a token
+func illegalChar(ch rune) Token {
+	return Token{Type: ILLEGAL, Literal: fmt.Sprintf("unexpected character %q", ch)}
+}
+
+// isLetterRune reports whether r can start an identifier: any Unicode letter or '_'
+func isLetterRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isDigitRune(r rune) bool {
+	return '0' <= r && r <= '9'
 }
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -53,3 +54,111 @@ func TestLexerInvalidNumericToken(t *testing.T) {
 		t.Logf(" %d: Type=%s, Literal=%s", i, token.Type, token.Literal)
 	}
 }
+
+func TestEnhancedLexerUnicode(t *testing.T) {
+	input := "Größe >= 10 AND Étiquettes.città = 'Zürich' AND 名前 = \"東京\""
+	want := []Token{
+		{Type: IDENTIFIER, Literal: "Größe", Column: 1},
+		{Type: GE, Literal: ">=", Column: 7},
+		{Type: NUMBER, Literal: "10", Column: 10},
+		{Type: AND, Literal: "AND", Column: 13},
+		{Type: IDENTIFIER, Literal: "Étiquettes.città", Column: 17},
+		{Type: EQ, Literal: "=", Column: 34},
+		{Type: STRING, Literal: "Zürich", Column: 36},
+		{Type: AND, Literal: "AND", Column: 45},
+		{Type: IDENTIFIER, Literal: "名前", Column: 49},
+		{Type: EQ, Literal: "=", Column: 52},
+		{Type: STRING, Literal: "東京", Column: 54},
+		{Type: EOF, Literal: "", Column: 58},
+	}
+
+	l := NewEnhancedLexer(input)
+	for i, w := range want {
+		if got := l.NextToken(); got != w {
+			t.Fatalf("token %d = %+v, want %+v", i, got, w)
+		}
+	}
+}
+
+func TestEnhancedLexerErrorColumns(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"Größe = @", "unexpected character '@' (column 9)"},
+		{"Größe ! 1", "unexpected character '!' (column 7)"},
+		{"名前 = '東京", "unclosed string: 東京 (column 6)"},
+		{"Étiquette.`città = 1", "unclosed quoted identifier: `città = 1 (column 1)"},
+	}
+	for _, tt := range tests {
+		l := NewEnhancedLexer(tt.input)
+		var tok Token
+		for tok = l.NextToken(); tok.Type != ILLEGAL && tok.Type != EOF; tok = l.NextToken() {
+		}
+		if tok.Type != ILLEGAL || tok.Literal != tt.want {
+			t.Errorf("%q: got %s %q, want ILLEGAL %q", tt.input, tok.Type, tok.Literal, tt.want)
+		}
+	}
+}
+
+func TestUnicodeQueries(t *testing.T) {
+	type Stadt struct {
+		Name      string
+		Größe     int
+		Latency   float64 // seconds
+		Etiketten map[string]string
+	}
+	cities := []Stadt{
+		{Name: "Zürich", Größe: 420000, Latency: 0.000005, Etiketten: map[string]string{"région": "ost", "名前": "チューリッヒ"}},
+		{Name: "Genève", Größe: 200000, Latency: 0.25, Etiketten: map[string]string{"région": "west", "名前": "ジュネーブ"}},
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"Größe > 300000", "Zürich"},
+		{"größe < 300000", "Genève"},
+		{"Etiketten.région = 'west'", "Genève"},
+		{"Etiketten.名前 = 'チューリッヒ'", "Zürich"},
+		{"Name = 'Genève'", "Genève"},
+		{"Latency < 10µs", "Zürich"},
+		{"Latency < 10μs", "Zürich"},
+		{"Latency < 10us", "Zürich"},
+		{"Latency > 100ms", "Genève"},
+		{"Latency > 1000000ns", "Genève"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, err := Parse(tt.query, cities)
+			if err != nil {
+				t.Fatalf("Parse returned error: %v", err)
+			}
+			if len(results) != 1 || results[0].Name != tt.want {
+				t.Errorf("got %v, want only %s", results, tt.want)
+			}
+		})
+	}
+
+	_, err := Parse("Größe = 1 AND Name ! 'x'", cities)
+	if err == nil || !strings.Contains(err.Error(), "column 20") {
+		t.Errorf("expected the error to point at column 20, got %v", err)
+	}
+
+	// Columns count the query as typed, before units are written out in full
+	for _, tc := range []struct {
+		query  string
+		column string
+	}{
+		{"Größe > 10 AND Name = !", "column 23"},
+		{"Größe > 10EiB AND Name = !", "column 26"},
+		{"Größe > 1,000 AND Name = !", "column 26"},
+		{"Latency < 10µs AND Name = !", "column 27"},
+		{"Größe > 10EiB AND Name = '東京", "unclosed string: 東京 (column 26)"},
+	} {
+		_, err := Parse(tc.query, cities)
+		if err == nil || !strings.Contains(err.Error(), tc.column) {
+			t.Errorf("Parse(%q): expected the error to point at %s, got %v", tc.query, tc.column, err)
+		}
+	}
+}
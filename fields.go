@@ -0,0 +1,321 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// The `parser` struct tag renames a field, adds aliases or hides it from queries:
+//
+//	CreatedAt time.Time `parser:"created,alias=created_at,alias=ctime"`
+//	Password  string    `parser:"-"`
+const tagName = "parser"
+
+// errFieldNotFound is wrapped by errors for path segments that match nothing
+var errFieldNotFound = errors.New("field not found")
+
+// structField is a queryable field of a struct type. index is the sequence of field
+// indexes to follow, which is longer than one for fields promoted from embedded structs.
+type structField struct {
+	index     []int
+	names     []string // Go names of the fields along index
+	embedded  []bool   // whether each field along index is an embedded struct
+	typ       reflect.Type
+	ambiguous bool
+}
+
+// structFields maps the lower-cased names a struct's fields can be queried by to the
+// fields themselves. It is built once per type and options combination.
+type structFields struct {
+	byName map[string]structField
+
+	// all holds each queryable field once, in declaration order, for * segments.
+	// Embedded structs are represented by the fields they promote.
+	all []structField
+}
+
+type structFieldsKey struct {
+	t          reflect.Type
+	jsonTags   bool
+	unexported UnexportedFieldPolicy
+}
+
+var structFieldsCache sync.Map // map[structFieldsKey]*structFields
+
+// cachedStructFields returns the field resolution table for t
+func cachedStructFields(t reflect.Type, opts *options) *structFields {
+	key := structFieldsKey{t: t, jsonTags: opts.jsonTags, unexported: opts.unexported}
+	if sf, ok := structFieldsCache.Load(key); ok {
+		return sf.(*structFields)
+	}
+	sf, _ := structFieldsCache.LoadOrStore(key, buildStructFields(t, opts))
+	return sf.(*structFields)
+}
+
+// fieldCandidate is one field a name could refer to while building a structFields table
+type fieldCandidate struct {
+	structField
+	depth  int
+	tagged bool
+}
+
+// buildStructFields follows Go's promotion rules: fields of anonymous embedded structs
+// are promoted, a shallower field hides deeper ones, and two fields with the same name
+// at the same depth make the name ambiguous. Names from tags win over Go field names
+// at the same depth.
+func buildStructFields(t reflect.Type, opts *options) *structFields {
+	candidates := map[string][]fieldCandidate{}
+	collectStructFields(t, structField{}, 0, map[reflect.Type]bool{t: true}, opts, candidates)
+
+	sf := &structFields{byName: make(map[string]structField, len(candidates))}
+	for name, cands := range candidates {
+		best := []fieldCandidate{}
+		for _, c := range cands {
+			switch {
+			case len(best) == 0 || c.depth < best[0].depth:
+				best = []fieldCandidate{c}
+			case c.depth == best[0].depth:
+				best = append(best, c)
+			}
+		}
+
+		tagged := best[:0:0]
+		for _, c := range best {
+			if c.tagged {
+				tagged = append(tagged, c)
+			}
+		}
+		if len(tagged) > 0 {
+			best = tagged
+		}
+
+		f := best[0].structField
+		f.ambiguous = len(best) > 1
+		sf.byName[name] = f
+	}
+
+	seen := map[string]bool{}
+	for _, f := range sf.byName {
+		key := fmt.Sprint(f.index)
+		if f.ambiguous || f.embedded[len(f.embedded)-1] || seen[key] {
+			continue
+		}
+		seen[key] = true
+		sf.all = append(sf.all, f)
+	}
+	slices.SortFunc(sf.all, func(a, b structField) int {
+		return slices.Compare(a.index, b.index)
+	})
+	return sf
+}
+
+// collectStructFields records every name the fields of t, including promoted ones, can be queried by
+func collectStructFields(t reflect.Type, parent structField, depth int, visiting map[reflect.Type]bool, opts *options, candidates map[string][]fieldCandidate) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		names, goName, ok := fieldNames(field, opts)
+		if !ok {
+			continue
+		}
+
+		// Unexported fields are only visible with UnexportedRead. Unexported embedded
+		// structs still promote their exported fields.
+		visible := field.IsExported() || opts.unexported == UnexportedRead
+
+		f := structField{
+			index:    append(append([]int(nil), parent.index...), i),
+			names:    append(append([]string(nil), parent.names...), field.Name),
+			embedded: append(append([]bool(nil), parent.embedded...), field.Anonymous),
+			typ:      field.Type,
+		}
+		if visible {
+			for _, name := range names {
+				candidates[name] = append(candidates[name], fieldCandidate{structField: f, depth: depth, tagged: true})
+			}
+			if goName != "" {
+				candidates[goName] = append(candidates[goName], fieldCandidate{structField: f, depth: depth})
+			}
+		}
+
+		// Promote the fields of embedded structs, unless a tag gave the embedded
+		// struct its own name, in which case it is only reachable as a nested field
+		if !field.Anonymous || len(names) > 0 && goName == "" {
+			continue
+		}
+		ft := field.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() != reflect.Struct || visiting[ft] {
+			continue
+		}
+		visiting[ft] = true
+		collectStructFields(ft, f, depth+1, visiting, opts, candidates)
+		delete(visiting, ft)
+	}
+}
+
+// fieldNames returns the case-folded tag names of a field and, if it is still
+// queryable by its Go name, that name. ok is false for fields tagged `parser:"-"`.
+func fieldNames(field reflect.StructField, opts *options) (tagNames []string, goName string, ok bool) {
+	goName = foldKey(field.Name)
+
+	if tag, found := field.Tag.Lookup(tagName); found {
+		if tag == "-" {
+			return nil, "", false
+		}
+		parts := strings.Split(tag, ",")
+		if name := strings.TrimSpace(parts[0]); name != "" {
+			// An explicit parser name replaces the Go name
+			tagNames = append(tagNames, foldKey(name))
+			goName = ""
+		}
+		for _, part := range parts[1:] {
+			part = strings.TrimSpace(part)
+			if alias, isAlias := strings.CutPrefix(part, "alias="); isAlias && alias != "" {
+				tagNames = append(tagNames, foldKey(alias))
+			}
+		}
+	}
+
+	if opts.jsonTags {
+		if tag, found := field.Tag.Lookup("json"); found {
+			name, _, _ := strings.Cut(tag, ",")
+			if name != "" && name != "-" {
+				tagNames = append(tagNames, foldKey(name))
+			}
+		}
+	}
+	return tagNames, goName, true
+}
+
+// lookup returns the field of a struct value queryable by name, matched
+// case-insensitively. A field promoted through a nil embedded pointer is returned as a
+// nil value, which compares as NULL.
+func (sf *structFields) lookup(val reflect.Value, name string) (reflect.Value, error) {
+	f, ok := sf.byName[foldKey(name)]
+	if !ok {
+		return reflect.Value{}, nil
+	}
+	if f.ambiguous {
+		return reflect.Value{}, fmt.Errorf("ambiguous field %q in %s", name, val.Type())
+	}
+	return f.get(val), nil
+}
+
+// get returns the field of a struct value, or a nil value if it is promoted through
+// a nil embedded pointer
+func (f structField) get(val reflect.Value) reflect.Value {
+	v := val
+	for i, idx := range f.index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return nullValue(f.typ)
+			}
+			v = v.Elem()
+		}
+		v = v.Field(idx)
+	}
+	return v
+}
+
+// nullValue returns a nil value standing in for a missing value of type t
+func nullValue(t reflect.Type) reflect.Value {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		return reflect.Zero(t)
+	}
+	return reflect.Zero(reflect.PointerTo(t))
+}
+
+// checkFieldPaths validates the field paths used by expr against the static type t,
+// so ambiguous promoted fields and fields reached through an alias of a denied field
+// are reported when the query is run on t, before any item is evaluated. Paths that
+// can't be followed statically (maps, interfaces) are left to evaluation.
+func checkFieldPaths(expr Expression, t reflect.Type, opts *options) error {
+	return checkScopedFieldPaths(expr, t, "", opts)
+}
+
+// checkScopedFieldPaths checks the paths in expr, which are relative to scope, the
+// path of the quantifiers expr is nested in
+func checkScopedFieldPaths(expr Expression, t reflect.Type, scope string, opts *options) error {
+	var err error
+	walkExpression(expr, func(e Expression) bool {
+		if err != nil {
+			return false
+		}
+		// A quantifier reads its own field, and its predicate the elements
+		if q, ok := e.(*QuantifierExpression); ok {
+			if err = checkFieldPath(joinPath(scope, q.Field), t, opts); err == nil {
+				err = checkScopedFieldPaths(q.Predicate, t, joinPath(scope, q.Field), opts)
+			}
+			return false
+		}
+		for _, field := range expressionFields(e) {
+			if err = checkFieldPath(joinPath(scope, field), t, opts); err != nil {
+				return false
+			}
+		}
+		return true
+	})
+	return err
+}
+
+func checkFieldPath(path string, t reflect.Type, opts *options) error {
+	opts = opts.orDefault()
+
+	// The path is also checked as Go field names, both with and without the
+	// embedded structs promoted fields come from, so tags and promotion can't be
+	// used to reach a denied field under another name. Access is checked before
+	// anything else so the error doesn't depend on whether the field exists.
+	var full []string
+	var embedded []bool
+	var resolveErr error
+	for _, part := range splitPath(path) {
+		if part == elementsName {
+			// The elements of a slice are read under the slice's names
+			for t != nil && t.Kind() == reflect.Ptr {
+				t = t.Elem()
+			}
+			if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+				t = t.Elem()
+				continue
+			}
+			full, embedded = append(full, part), append(embedded, false)
+			t = nil
+			continue
+		}
+		for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			t = t.Elem()
+		}
+		var f structField
+		var ok bool
+		if t != nil && t.Kind() == reflect.Struct {
+			f, ok = cachedStructFields(t, opts).byName[foldKey(part)]
+		}
+		if !ok {
+			full, embedded = append(full, part), append(embedded, false)
+			t = nil
+			continue
+		}
+		if f.ambiguous && resolveErr == nil {
+			resolveErr = fmt.Errorf("ambiguous field %q in %s", part, t)
+		}
+		full, embedded = append(full, f.names...), append(embedded, f.embedded...)
+		t = f.typ
+	}
+	var short []string
+	for i, name := range full {
+		if !embedded[i] || i == len(full)-1 {
+			short = append(short, name)
+		}
+	}
+	if err := opts.checkFieldAccess(path, canonicalPath(path), strings.Join(full, "."), strings.Join(short, ".")); err != nil {
+		return err
+	}
+	return resolveErr
+}
@@ -0,0 +1,331 @@
+package parser
+
+import (
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStructTagFieldNames(t *testing.T) {
+	type User struct {
+		Name         string `json:"name"`
+		CreatedAt    int64  `json:"created_at"`
+		LastLogin    int64  `json:"last_login,omitempty" parser:"login,alias=seen"`
+		PasswordHash string `json:"password_hash" parser:"-"`
+		Internal     string `json:"-"`
+		Region       string `parser:",alias=zone"`
+	}
+
+	users := []User{
+		{Name: "alice", CreatedAt: 100, LastLogin: 500, PasswordHash: "x", Internal: "i", Region: "eu"},
+		{Name: "bob", CreatedAt: 200, LastLogin: 0, PasswordHash: "y", Internal: "j", Region: "us"},
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		opts     []Option
+		expected []string
+		wantErr  bool
+	}{
+		{"Go name without JSON tags", "CreatedAt > 150", nil, []string{"bob"}, false},
+		{"JSON name needs WithJSONTags", "created_at > 150", nil, nil, true},
+		{"JSON name", "created_at > 150", []Option{WithJSONTags()}, []string{"bob"}, false},
+		{"JSON name is case-insensitive", "Created_At > 150", []Option{WithJSONTags()}, []string{"bob"}, false},
+		{"Go name still works with JSON tags", "CreatedAt > 150", []Option{WithJSONTags()}, []string{"bob"}, false},
+		{"Parser tag name", "login > 100", nil, []string{"alice"}, false},
+		{"Parser tag alias", "seen > 100", nil, []string{"alice"}, false},
+		{"Parser tag replaces Go name", "LastLogin > 100", nil, nil, true},
+		{"Parser tag wins over JSON tag", "last_login > 100", []Option{WithJSONTags()}, []string{"alice"}, false},
+		{"Alias keeps Go name", "Region = 'eu' OR zone = 'us'", nil, []string{"alice", "bob"}, false},
+		{"Excluded field by Go name", "PasswordHash = 'x'", nil, nil, true},
+		{"Excluded field by JSON name", "password_hash = 'x'", []Option{WithJSONTags()}, nil, true},
+		{"json:\"-\" keeps Go name", "Internal = 'i'", []Option{WithJSONTags()}, []string{"alice"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := Parse(tt.query, users, tt.opts...)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse(%q) expected error, got %v", tt.query, results)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.query, err)
+			}
+			checkNames(t, tt.query, results, tt.expected)
+		})
+	}
+}
+
+func TestStructFieldsCache(t *testing.T) {
+	type Item struct {
+		ID int `json:"id"`
+	}
+	typ := reflect.TypeOf(Item{})
+
+	a := cachedStructFields(typ, &options{})
+	b := cachedStructFields(typ, &options{})
+	if a != b {
+		t.Errorf("expected the field table to be cached per type")
+	}
+	if c := cachedStructFields(typ, &options{jsonTags: true}); c == a {
+		t.Errorf("expected a separate table when JSON tags are enabled")
+	}
+}
+
+type BaseModel struct {
+	ID      int
+	Created int64
+}
+
+type Audit struct {
+	Created int64
+	Editor  string
+}
+
+type Owner struct {
+	Name string
+}
+
+type Server struct {
+	BaseModel
+	*Owner
+	Name string
+}
+
+type AuditedServer struct {
+	BaseModel
+	Audit
+	Name string
+}
+
+// Node embeds a pointer to its own type
+type Node struct {
+	*Node
+	Value int
+}
+
+func TestEmbeddedFieldPromotion(t *testing.T) {
+	servers := []Server{
+		{BaseModel: BaseModel{ID: 1}, Owner: &Owner{Name: "ops"}, Name: "web"},
+		{BaseModel: BaseModel{ID: 2}, Owner: nil, Name: "db"},
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"ID = 1", []string{"web"}},
+		{"id > 0", []string{"web", "db"}},
+		{"BaseModel.ID = 2", []string{"db"}},
+		// The outer Name hides Owner.Name
+		{"Name = 'web'", []string{"web"}},
+		{"Owner IS NULL", []string{"db"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, err := Parse(tt.query, servers)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.query, err)
+			}
+			checkNames(t, tt.query, results, tt.expected)
+		})
+	}
+}
+
+func TestEmbeddedNilPointerIsNull(t *testing.T) {
+	type Named struct {
+		*Owner
+		ID int
+	}
+	items := []Named{{Owner: &Owner{Name: "a"}, ID: 1}, {ID: 2}}
+
+	results, err := Parse("Name = 'a'", items)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != 1 {
+		t.Errorf("expected only item 1, got %+v", results)
+	}
+
+	results, err = Parse("Name IS NULL", items)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != 2 {
+		t.Errorf("expected only item 2, got %+v", results)
+	}
+}
+
+func TestAmbiguousEmbeddedField(t *testing.T) {
+	servers := []AuditedServer{{BaseModel: BaseModel{ID: 1, Created: 5}, Audit: Audit{Created: 6}, Name: "web"}}
+
+	// Compile doesn't know the type, so the ambiguity is reported by Filter, even
+	// when there is no data to evaluate
+	q, err := Compile("Created > 1")
+	if err != nil {
+		t.Fatalf("Compile returned error: %v", err)
+	}
+	if _, err := Filter(q, []AuditedServer(nil)); err == nil || !strings.Contains(err.Error(), "ambiguous field") {
+		t.Errorf("expected an ambiguous field error from Filter, got %v", err)
+	}
+	for _, data := range [][]AuditedServer{servers, nil} {
+		_, err := Parse("Created > 1", data)
+		if err == nil || !strings.Contains(err.Error(), "ambiguous field") {
+			t.Errorf("expected an ambiguous field error, got %v", err)
+		}
+	}
+
+	// Qualified paths and unambiguous promoted fields still work
+	for _, query := range []string{"Audit.Created = 6", "BaseModel.Created = 5", "Editor IS ZERO", "ID = 1"} {
+		results, err := Parse(query, servers)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", query, err)
+		}
+		if len(results) != 1 {
+			t.Errorf("Parse(%q) returned %d results, want 1", query, len(results))
+		}
+	}
+}
+
+func TestRecursiveEmbedding(t *testing.T) {
+	nodes := []Node{{Node: &Node{Value: 1}, Value: 2}, {Value: 3}}
+
+	results, err := Parse("Value > 1", nodes)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("expected 2 results, got %d", len(results))
+	}
+
+	results, err = Parse("Node.Value = 1", nodes[:1])
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("expected 1 result, got %d", len(results))
+	}
+}
+
+type secretHolder struct {
+	Label string
+}
+
+type Vault struct {
+	secretHolder
+	Name     string
+	password string
+	pin      int
+	active   bool
+	tags     []string
+	Amount   *big.Int
+	balance  *big.Int
+}
+
+// panicky panics when compared, standing in for buggy user code
+type panicky struct{}
+
+func (panicky) CompareDecimal(string) (int, error) {
+	panic("boom")
+}
+
+func TestUnexportedFieldPolicy(t *testing.T) {
+	vaults := []Vault{
+		{secretHolder: secretHolder{Label: "x"}, Name: "a", password: "hunter2", pin: 1234, active: true, tags: []string{"gold"}, balance: big.NewInt(5)},
+		{secretHolder: secretHolder{Label: "y"}, Name: "b", password: "swordfish", pin: 42, tags: []string{"silver"}},
+	}
+
+	skipped := []string{"password = 'hunter2'", "pin > 1000", "active = true", "tags CONTAINS 'gold'", "secretHolder.Label = 'x'"}
+	for _, query := range skipped {
+		t.Run("skip/"+query, func(t *testing.T) {
+			_, err := Parse(query, vaults)
+			if err == nil || !strings.Contains(err.Error(), "not found") {
+				t.Errorf("expected unexported field to be unknown, got %v", err)
+			}
+		})
+	}
+
+	// Exported fields promoted from an unexported embedded struct remain visible
+	results, err := Parse("Label = 'x'", vaults)
+	if err != nil || len(results) != 1 {
+		t.Errorf("expected promoted Label to match once, got %v, %v", results, err)
+	}
+
+	read := []struct {
+		query    string
+		expected int
+	}{
+		{"password = 'hunter2'", 1},
+		{"pin > 1000", 1},
+		{"active = true", 1},
+		{"tags CONTAINS 'gold'", 1},
+		{"ANY(tags) = ANY('gold', 'silver')", 2},
+		{"secretHolder.Label = 'y'", 1},
+	}
+	for _, tt := range read {
+		t.Run("read/"+tt.query, func(t *testing.T) {
+			results, err := Parse(tt.query, vaults, WithUnexportedFields(UnexportedRead))
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.query, err)
+			}
+			if len(results) != tt.expected {
+				t.Errorf("Parse(%q) returned %d results, want %d", tt.query, len(results), tt.expected)
+			}
+		})
+	}
+
+	// math/big values can't be read from unexported fields, which is an error rather than a panic
+	if _, err := Parse("balance > 1", vaults[:1], WithUnexportedFields(UnexportedRead)); err == nil {
+		t.Errorf("expected an error reading an unexported big.Int")
+	}
+}
+
+func TestParseNeverPanics(t *testing.T) {
+	type Weird struct {
+		Name  string
+		Value panicky
+		Any   any
+		Fn    func()
+		Ch    chan int
+		Map   map[int]string
+	}
+	data := []Weird{{Name: "a", Any: 1, Fn: func() {}, Ch: make(chan int), Map: map[int]string{1: "x"}}}
+
+	queries := []string{
+		"Value = 1",
+		"Fn = 1",
+		"Ch > 2",
+		"Map.1 = 'x'",
+		"Any = 'x'",
+		"Name.Foo.Bar = 1",
+		"((((Name = 'a'",
+		"NOT NOT NOT",
+		"ANY(Name) = ANY(",
+		"Name = 'a' AND AND OR",
+		")))",
+		"'unterminated",
+		"Name <> 'a'",
+		"UPPER(",
+		"Name IS NOT",
+	}
+	for _, query := range queries {
+		t.Run(query, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("Parse(%q) panicked: %v", query, r)
+				}
+			}()
+			_, _ = Parse(query, data)
+		})
+	}
+
+	if _, err := Parse("Value = 1", data); err == nil || !strings.Contains(err.Error(), "internal error") {
+		t.Errorf("expected a recovered panic to be returned as an error, got %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package parser
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// resultNames returns the name of each item in results, a slice of structs with a
+// Name field or of maps with a "name" key, or of pointers or interfaces to them
+func resultNames(results any) []string {
+	v := reflect.ValueOf(results)
+	var names []string
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i)
+		for item.Kind() == reflect.Ptr || item.Kind() == reflect.Interface {
+			item = item.Elem()
+		}
+		if item.Kind() == reflect.Map {
+			item = item.MapIndex(reflect.ValueOf("name")).Elem()
+		} else {
+			item = item.FieldByName("Name")
+		}
+		names = append(names, item.String())
+	}
+	return names
+}
+
+// checkNames reports an error unless results, as read by resultNames, are the
+// items named in want, in order
+func checkNames(t *testing.T, query string, results any, want []string) {
+	t.Helper()
+	if got := resultNames(results); !slices.Equal(got, want) {
+		t.Errorf("Parse(%q) = %q, want %q", query, got, want)
+	}
+}
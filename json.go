@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ParseJSON compiles query and returns the records of data that match it. It is
+// shorthand for Compile followed by FilterJSON.
+func ParseJSON(query string, data []byte, opts ...Option) ([]json.RawMessage, error) {
+	q, err := Compile(query, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return FilterJSON(q, data)
+}
+
+// FilterJSON returns the records of data that match q, without a Go type to decode
+// them into. data is either a JSON array of objects or newline-delimited JSON, one
+// object per line. Records are queried as encoding/json decodes them into any:
+// numbers are float64, and null is NULL. Matching records are returned as written.
+func FilterJSON(q *Query, data []byte) ([]json.RawMessage, error) {
+	records, err := splitJSONRecords(data)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]any, len(records))
+	for i, record := range records {
+		if err := json.Unmarshal(record, &values[i]); err != nil {
+			return nil, fmt.Errorf("invalid JSON in record %d: %w", i+1, err)
+		}
+	}
+
+	if q != nil && q.expr == nil {
+		return records, nil
+	}
+	results := make([]json.RawMessage, 0, len(records))
+	err = filter(q, values, func(i int) {
+		results = append(results, records[i])
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// splitJSONRecords returns the elements of a JSON array, or the values of a
+// newline-delimited JSON stream
+func splitJSONRecords(data []byte) ([]json.RawMessage, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var records []json.RawMessage
+		if err := json.Unmarshal(trimmed, &records); err != nil {
+			return nil, fmt.Errorf("invalid JSON array: %w", err)
+		}
+		return records, nil
+	}
+
+	var records []json.RawMessage
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	for {
+		var record json.RawMessage
+		err := dec.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON in record %d: %w", len(records)+1, err)
+		}
+		records = append(records, record)
+	}
+}
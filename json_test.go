@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const jsonUsers = `[
+	{"name": "alice", "age": 30, "admin": true, "tags": ["go", null], "manager": null, "address": {"city": "Oslo"}, "orders": [{"total": 12.5}, null]},
+	{"name": "bob", "age": 41.5, "admin": false, "tags": ["rust"], "manager": {"name": "alice"}, "address": {"city": "Bergen"}, "orders": [{"total": 3}]}
+]`
+
+func TestFilterMapRecords(t *testing.T) {
+	var records []map[string]any
+	if err := json.Unmarshal([]byte(jsonUsers), &records); err != nil {
+		t.Fatal(err)
+	}
+	var values []any
+	if err := json.Unmarshal([]byte(jsonUsers), &values); err != nil {
+		t.Fatal(err)
+	}
+	values = append(values, nil) // nil records are skipped
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"String", "name = 'alice'", []string{"alice"}},
+		{"Key case", "Name = 'bob'", []string{"bob"}},
+		{"Integer as float64", "age = 30", []string{"alice"}},
+		{"Fractional number", "age > 40", []string{"bob"}},
+		{"Bool", "admin = true", []string{"alice"}},
+		{"Null is NULL", "manager IS NULL", []string{"alice"}},
+		{"Below null is NULL", "manager.name IS NULL", []string{"alice"}},
+		{"Nested object", "manager.name = 'alice'", []string{"bob"}},
+		{"Array", "tags = 'rust'", []string{"bob"}},
+		{"Array with null", "tags CONTAINS ALL ('go')", []string{"alice"}},
+		{"Array of objects", "orders.total < 5", []string{"bob"}},
+		{"Quantifier", "orders ANY (total > 10)", []string{"alice"}},
+		{"Index", "orders[1].total IS NULL", []string{"alice", "bob"}},
+		{"Recursive", "..city = 'Bergen'", []string{"bob"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := Compile(tt.query)
+			if err != nil {
+				t.Fatalf("Compile(%q) returned error: %v", tt.query, err)
+			}
+
+			maps, err := Filter(q, records)
+			if err != nil {
+				t.Fatalf("Filter([]map[string]any) returned error: %v", err)
+			}
+			checkNames(t, tt.query, maps, tt.want)
+
+			anys, err := Filter(q, values)
+			if err != nil {
+				t.Fatalf("Filter([]any) returned error: %v", err)
+			}
+			checkNames(t, tt.query, anys, tt.want)
+		})
+	}
+
+	if _, err := Parse("age > 1", []any{1.5}); err == nil || !strings.Contains(err.Error(), "expected slice of structs or maps") {
+		t.Errorf("expected an error for a number record, got %v", err)
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	ndjson := "{\"name\": \"alice\", \"age\": 30}\n{\"name\": \"bob\",   \"age\": 45}\n\n"
+	tests := []struct {
+		name  string
+		query string
+		data  string
+		want  []string
+	}{
+		{"Array", "age > 35", jsonUsers, []string{`"bob"`}},
+		{"NDJSON", "age > 35", ndjson, []string{`"bob"`}},
+		{"Empty query", "", ndjson, []string{`"alice"`, `"bob"`}},
+		{"No records", "age > 35", "  ", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := ParseJSON(tt.query, []byte(tt.data))
+			if err != nil {
+				t.Fatalf("ParseJSON(%q) returned error: %v", tt.query, err)
+			}
+			if len(results) != len(tt.want) {
+				t.Fatalf("ParseJSON(%q) returned %d records, want %d", tt.query, len(results), len(tt.want))
+			}
+			for i, record := range results {
+				var r struct{ Name json.RawMessage }
+				if err := json.Unmarshal(record, &r); err != nil {
+					t.Fatalf("record %s is not valid JSON: %v", record, err)
+				}
+				if string(r.Name) != tt.want[i] {
+					t.Errorf("record %d is %s, want name %s", i, record, tt.want[i])
+				}
+			}
+		})
+	}
+
+	// Records are returned as written
+	results, err := ParseJSON("name = 'bob'", []byte(ndjson))
+	if err != nil || len(results) != 1 || string(results[0]) != `{"name": "bob",   "age": 45}` {
+		t.Errorf("ParseJSON returned %s, %v", results, err)
+	}
+
+	errorTests := []struct {
+		name  string
+		query string
+		data  string
+		want  string
+	}{
+		{"Bad array", "age > 1", `[{"age": 1},`, "invalid JSON array"},
+		{"Bad line", "age > 1", "{\"age\": 1}\n{\"age\": ", "invalid JSON in record 2"},
+		{"Not objects", "age > 1", `[1, 2]`, "expected slice of structs or maps"},
+		{"Bad query", "age > 1 AND )", `[]`, "parse"},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseJSON(tt.query, []byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseJSON(%q) error = %v, want %q", tt.query, err, tt.want)
+			}
+		})
+	}
+
+	_, err = ParseJSON("password = 'x'", []byte(ndjson), WithDeniedFields("password"))
+	if !errors.Is(err, ErrFieldNotAllowed) {
+		t.Errorf("expected ErrFieldNotAllowed, got %v", err)
+	}
+}
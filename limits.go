@@ -0,0 +1,133 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned, wrapped, when a query exceeds one of its Limits.
+var (
+	ErrQueryTooLong      = errors.New("query is too long")
+	ErrTooManyTokens     = errors.New("query has too many tokens")
+	ErrQueryTooDeep      = errors.New("query is nested too deeply")
+	ErrTooManyPredicates = errors.New("query has too many predicates")
+	ErrListTooLong       = errors.New("value list is too long")
+	ErrNumberTooLarge    = errors.New("numeric literal is too large")
+)
+
+// Limits bounds the size of a query, for queries that come from untrusted input.
+// A zero field means no limit.
+type Limits struct {
+	MaxQueryLength     int // bytes in the query string, before and after unit expansion
+	MaxTokens          int // tokens produced by the lexer
+	MaxDepth           int // nesting of parentheses and NOT
+	MaxPredicates      int // comparisons, ANY and IS NULL tests
+	MaxListSize        int // values in a single ANY(...) list
+	MaxLiteralDigits   int // digits in a numeric literal, after unit expansion
+	MaxLiteralExponent int // magnitude of the exponent of a numeric literal
+}
+
+// DefaultLimits is a starting point for user-facing endpoints. It is not applied
+// unless passed to WithLimits.
+var DefaultLimits = Limits{
+	MaxQueryLength: 4096,
+	MaxTokens:      1024,
+	MaxDepth:       32,
+	MaxPredicates:  128,
+	MaxListSize:    256,
+
+	MaxLiteralDigits:   64,
+	MaxLiteralExponent: 308,
+}
+
+// WithLimits rejects queries exceeding l while they are lexed and parsed, before
+// any deep recursion or large allocation happens.
+func WithLimits(l Limits) Option {
+	return func(o *options) {
+		o.limits = l
+	}
+}
+
+// exceeded returns err wrapped with the limit that was hit
+func exceeded(err error, limit int) error {
+	return fmt.Errorf("%w (limit %d)", err, limit)
+}
+
+// failLimit records a limit error and stops parsing by making the rest of the
+// input look like EOF
+func (p *Parser) failLimit(err error) {
+	if p.limitErr == nil {
+		p.limitErr = err
+		p.errors = append(p.errors, err.Error())
+	}
+	p.currentToken = Token{Type: EOF}
+	p.peekToken = Token{Type: EOF}
+}
+
+// enterNesting is called when parsePrimary starts a (possibly nested) expression.
+// It reports false if that exceeds MaxDepth; otherwise leaveNesting must follow.
+func (p *Parser) enterNesting() bool {
+	if max := p.opts.orDefault().limits.MaxDepth; max > 0 && p.depth >= max {
+		p.failLimit(exceeded(ErrQueryTooDeep, max))
+		return false
+	}
+	p.depth++
+	return true
+}
+
+func (p *Parser) leaveNesting() {
+	p.depth--
+}
+
+// countPredicate is called for every comparison, ANY and IS NULL test
+func (p *Parser) countPredicate() bool {
+	p.predicates++
+	if max := p.opts.orDefault().limits.MaxPredicates; max > 0 && p.predicates > max {
+		p.failLimit(exceeded(ErrTooManyPredicates, max))
+		return false
+	}
+	return true
+}
+
+// checkListSize is called for every value added to an ANY(...) list
+func (p *Parser) checkListSize(n int) bool {
+	if max := p.opts.orDefault().limits.MaxListSize; max > 0 && n > max {
+		p.failLimit(exceeded(ErrListTooLong, max))
+		return false
+	}
+	return true
+}
+
+// checkLiteralSize is called for every numeric literal, with its digits and
+// exponent as counted by literalSize
+func (p *Parser) checkLiteralSize(digits, exponent int) bool {
+	limits := p.opts.orDefault().limits
+	if max := limits.MaxLiteralDigits; max > 0 && digits > max {
+		p.failLimit(exceeded(ErrNumberTooLarge, max))
+		return false
+	}
+	if max := limits.MaxLiteralExponent; max > 0 && exponent > max {
+		p.failLimit(exceeded(ErrNumberTooLarge, max))
+		return false
+	}
+	return true
+}
+
+// Complexity returns a score for the cost of evaluating q against one item, for
+// rate limiting or rejecting expensive queries. Every predicate, every value in an
+// ANY list and every AND, OR and NOT counts one.
+func (q *Query) Complexity() int {
+	if q == nil || q.expr == nil {
+		return 0
+	}
+	score := 0
+	walkExpression(q.expr, func(e Expression) bool {
+		if ae, ok := e.(*AnyExpression); ok {
+			score += len(ae.Values)
+		} else {
+			score++
+		}
+		return true
+	})
+	return score
+}
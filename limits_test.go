@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestQueryLimits(t *testing.T) {
+	deep := strings.Repeat("(", 50) + "Age > 1" + strings.Repeat(")", 50)
+	nots := strings.Repeat("NOT ", 50) + "Age > 1"
+	manyOr := "Age = 1" + strings.Repeat(" OR Age = 1", 20)
+	list := "ANY(Age) = ANY(" + strings.TrimSuffix(strings.Repeat("1, ", 20), ", ") + ")"
+
+	tests := []struct {
+		name   string
+		query  string
+		limits Limits
+		want   error
+	}{
+		{"Within all limits", "Age > 1 AND Name = 'a'", DefaultLimits, nil},
+		{"Query length", strings.Repeat(" ", 100) + "Age > 1", Limits{MaxQueryLength: 50}, ErrQueryTooLong},
+		{"Token count", manyOr, Limits{MaxTokens: 30}, ErrTooManyTokens},
+		{"Parenthesis depth", deep, Limits{MaxDepth: 10}, ErrQueryTooDeep},
+		{"NOT depth", nots, Limits{MaxDepth: 10}, ErrQueryTooDeep},
+		{"Depth at the limit", "((Age > 1))", Limits{MaxDepth: 3}, nil},
+		{"Depth over the limit", "(((Age > 1)))", Limits{MaxDepth: 3}, ErrQueryTooDeep},
+		{"Predicates", manyOr, Limits{MaxPredicates: 10}, ErrTooManyPredicates},
+		{"Predicates at the limit", "Age = 1 OR Age = 2", Limits{MaxPredicates: 2}, nil},
+		{"List size", list, Limits{MaxListSize: 5}, ErrListTooLong},
+		{"List size at the limit", "ANY(Age) = ANY(1, 2, 3)", Limits{MaxListSize: 3}, nil},
+		{"Zero limits are unlimited", deep, Limits{}, nil},
+		{"Length after unit expansion", "Age > 1e90K", Limits{MaxQueryLength: 50}, ErrQueryTooLong},
+		{"Literal digits", "Age > 1234567890", Limits{MaxLiteralDigits: 5}, ErrNumberTooLarge},
+		{"Literal digits after unit expansion", "Age > 1e200K", DefaultLimits, ErrNumberTooLarge},
+		{"Literal exponent", "Age > 1e-400", DefaultLimits, ErrNumberTooLarge},
+		{"Literal in a list", "ANY(Age) = ANY(1, 1e99999)", DefaultLimits, ErrNumberTooLarge},
+		{"Literal at the limit", "Age > 12345 AND Age < 1e5", Limits{MaxLiteralDigits: 5, MaxLiteralExponent: 5}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.query, WithLimits(tt.limits))
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Compile returned error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Compile error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryComplexity(t *testing.T) {
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", 0},
+		{"Age > 1", 1},
+		{"Age > 1 AND Name = 'a'", 3},
+		{"NOT (Age > 1 OR Age < 0)", 4},
+		{"ANY(Age) = ANY(1, 2, 3) AND Name IS NULL", 5},
+	}
+	for _, tt := range tests {
+		q, err := Compile(tt.query)
+		if err != nil {
+			t.Fatalf("Compile(%q) returned error: %v", tt.query, err)
+		}
+		if got := q.Complexity(); got != tt.want {
+			t.Errorf("Complexity(%q) = %d, want %d", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package parser
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// HasKeyExpression checks whether a map field has a key: Labels HAS KEY 'env'. Keys
+// match as in field paths, so string keys are case-insensitive by default.
+type HasKeyExpression struct {
+	Field string
+	Key   string
+
+	opts *options
+}
+
+// Evaluate for HasKeyExpression. A field that isn't a map has no keys.
+func (he *HasKeyExpression) Evaluate(item reflect.Value) (bool, error) {
+	fieldValues, err := lookupField(item, he.Field, he.opts)
+	if err != nil {
+		return false, err
+	}
+	null := false
+	for _, v := range fieldValues {
+		isNull, err := isNullValue(v)
+		if err != nil {
+			return false, fmt.Errorf("failed to read value of field '%s': %w", he.Field, err)
+		}
+		if isNull {
+			null = true
+			continue
+		}
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				break
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Map {
+			continue
+		}
+		value, err := mapLookup(v, he.Key, he.opts)
+		if err != nil {
+			return false, err
+		}
+		if value.IsValid() {
+			return true, nil
+		}
+	}
+	return he.opts.settle(false, null, nil)
+}
+
+// keyPath returns the path of the map entry the expression tests for, which is
+// what allow and deny lists are checked against
+func (he *HasKeyExpression) keyPath() string {
+	return he.Field + "[" + Quote(he.Key) + "]"
+}
+
+// atHasKey reports whether the tokens after a field are HAS KEY. HAS and KEY are
+// only keywords here, so fields named Has or Key can still be queried.
+func (p *Parser) atHasKey() bool {
+	return p.currentTokenIs(IDENTIFIER) && strings.EqualFold(p.currentToken.Literal, "HAS") &&
+		p.peekToken.Type == IDENTIFIER && strings.EqualFold(p.peekToken.Literal, "KEY")
+}
+
+// parseHasKey parses HAS KEY 'key' after field
+func (p *Parser) parseHasKey(field string) Expression {
+	p.nextToken() // consume HAS
+	p.nextToken() // consume KEY
+	if !p.currentTokenIs(STRING) && !p.currentTokenIs(NUMBER) {
+		p.errorf("expected string or number key after HAS KEY")
+		return nil
+	}
+	he := &HasKeyExpression{Field: field, Key: p.currentToken.Literal, opts: p.opts}
+	if !p.checkField(he.keyPath()) {
+		return nil
+	}
+	p.nextToken() // consume key
+	return he
+}
+
+// pathFunctions read the keys or the values of a map, KEYS(Labels) and
+// VALUES(Labels), or the type of a value, TYPE(Payload) or TYPE() for the item
+var pathFunctions = []string{"KEYS", "VALUES", "TYPE"}
+
+func isPathFunction(name string) bool {
+	for _, fn := range pathFunctions {
+		if strings.EqualFold(name, fn) {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePathFunction parses KEYS(Field), VALUES(Field) or TYPE(Field) and returns
+// it as a field path, consuming the closing parenthesis
+func (p *Parser) parsePathFunction() string {
+	fn := strings.ToUpper(p.currentToken.Literal)
+	p.nextToken() // consume the function name
+	p.nextToken() // consume '('
+	if fn == "TYPE" && p.currentTokenIs(RPAREN) {
+		p.nextToken() // consume ')'
+		return "TYPE()"
+	}
+	if !p.currentTokenIs(IDENTIFIER) {
+		p.errorf("expected field name in %s()", fn)
+		return ""
+	}
+	field := fn + "(" + p.currentToken.Literal + ")"
+	p.nextToken() // consume field
+	if !p.currentTokenIs(RPAREN) {
+		p.errorf("expected ')' after field name in %s()", fn)
+		return ""
+	}
+	p.nextToken() // consume ')'
+	return field
+}
+
+// cutPathFunction splits a path ending in KEYS(...), VALUES(...) or TYPE(...), such
+// as Items.KEYS(Labels) inside a quantifier, into the function and the path it reads
+func cutPathFunction(path string) (fn, inner string, ok bool) {
+	if !strings.HasSuffix(path, ")") {
+		return "", "", false
+	}
+	upper := strings.ToUpper(path)
+	for _, fn := range pathFunctions {
+		for i := strings.Index(upper, fn+"("); i >= 0; {
+			if i == 0 || path[i-1] == '.' {
+				inner := path[:i] + path[i+len(fn)+1:len(path)-1]
+				return fn, strings.TrimSuffix(inner, "."), true
+			}
+			next := strings.Index(upper[i+1:], fn+"(")
+			if next < 0 {
+				break
+			}
+			i += next + 1
+		}
+	}
+	return "", "", false
+}
+
+// MapKeyMatching decides how names in a field path are matched against string map keys.
+type MapKeyMatching int
+
+const (
+	// MapKeyFold uses the key as written if the map has it, and otherwise a key that
+	// matches case-insensitively. If several do, the smallest one is used. This is
+	// the default.
+	MapKeyFold MapKeyMatching = iota
+
+	// MapKeyExact only uses the key as written, so Env and env are different keys.
+	MapKeyExact
+
+	// MapKeyFoldStrict is like MapKeyFold, but fails the query instead of choosing
+	// when there is no exact match and several keys match case-insensitively.
+	MapKeyFoldStrict
+)
+
+// WithMapKeyMatching sets how string map keys are matched.
+func WithMapKeyMatching(mode MapKeyMatching) Option {
+	return func(o *options) {
+		o.mapKeys = mode
+	}
+}
+
+// mapLookup returns the value of a map for a key written in a query. String keys,
+// including named string types, are matched as set by WithMapKeyMatching; other
+// keys are parsed as the key type, so a map[int]T can be queried as Scores.1 or
+// Scores[1]. A missing key is an invalid value.
+func mapLookup(m reflect.Value, key string, opts *options) (reflect.Value, error) {
+	keyType := m.Type().Key()
+	if keyType.Kind() == reflect.String {
+		return getMapValue(m, key, opts.orDefault().mapKeys)
+	}
+
+	k := reflect.New(keyType).Elem()
+	switch keyType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(key, 10, keyType.Bits())
+		if err != nil {
+			return reflect.Value{}, nil
+		}
+		k.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(key, 10, keyType.Bits())
+		if err != nil {
+			return reflect.Value{}, nil
+		}
+		k.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(key, keyType.Bits())
+		if err != nil {
+			return reflect.Value{}, nil
+		}
+		k.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(key)
+		if err != nil {
+			return reflect.Value{}, nil
+		}
+		k.SetBool(b)
+	default:
+		return reflect.Value{}, nil
+	}
+
+	return unwrapInterface(m.MapIndex(k)), nil
+}
+
+// unwrapInterface returns the value held by an interface value, such as a value in
+// a map[string]any or an element of a []any. A nil one, such as a JSON null, is NULL
+// rather than missing.
+func unwrapInterface(v reflect.Value) reflect.Value {
+	if v.Kind() != reflect.Interface {
+		return v
+	}
+	if v.IsNil() {
+		return missingValue
+	}
+	return v.Elem()
+}
+
+// sortedMapKeys returns the keys of m in order, so wildcards and KEYS() see map
+// entries in the same order every time
+func sortedMapKeys(m reflect.Value) []reflect.Value {
+	keys := m.MapKeys()
+	slices.SortFunc(keys, func(a, b reflect.Value) int {
+		switch a.Kind() {
+		case reflect.String:
+			return cmp.Compare(a.String(), b.String())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return cmp.Compare(a.Int(), b.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return cmp.Compare(a.Uint(), b.Uint())
+		case reflect.Float32, reflect.Float64:
+			return cmp.Compare(a.Float(), b.Float())
+		case reflect.Bool:
+			return cmp.Compare(strconv.FormatBool(a.Bool()), strconv.FormatBool(b.Bool()))
+		}
+		return 0
+	})
+	return keys
+}
+
+// expandSegment applies a * or KEYS() segment to a map or struct: the keys of a
+// map, or the values of a map or the queryable fields of a struct
+func expandSegment(val reflect.Value, seg pathSegment, opts *options) []reflect.Value {
+	var values []reflect.Value
+	switch val.Kind() {
+	case reflect.Map:
+		for _, key := range sortedMapKeys(val) {
+			if seg.keys {
+				values = append(values, key)
+				continue
+			}
+			values = append(values, unwrapInterface(val.MapIndex(key)))
+		}
+	case reflect.Struct:
+		if seg.keys {
+			return nil
+		}
+		for _, f := range cachedStructFields(val.Type(), opts.orDefault()).all {
+			values = append(values, f.get(val))
+		}
+	}
+	return values
+}
@@ -0,0 +1,225 @@
+package parser
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type envName string
+
+type mapProfile struct {
+	Level string
+	Years int
+}
+
+type mapRecord struct {
+	Name     string
+	Labels   map[string]string
+	Metadata map[string]mapProfile
+	Counts   map[int]int
+	Envs     map[envName]bool
+	Extra    map[string]any
+	Items    []map[string]int
+}
+
+func TestMapPredicates(t *testing.T) {
+	records := []mapRecord{
+		{
+			Name:     "a",
+			Labels:   map[string]string{"env": "prod", "team": "core"},
+			Metadata: map[string]mapProfile{"go": {Level: "senior", Years: 8}, "rust": {Level: "junior", Years: 1}},
+			Counts:   map[int]int{1: 10, 2: 20, 3: 0},
+			Envs:     map[envName]bool{"prod": true, "dev": false},
+			Extra:    map[string]any{"nested": map[string]any{"x": 1, "y": 0}},
+			Items:    []map[string]int{{"q": 1}, {"r": 2}},
+		},
+		{
+			Name:     "b",
+			Labels:   map[string]string{"owner": "ops"},
+			Metadata: map[string]mapProfile{"java": {Level: "mid", Years: 4}},
+			Counts:   map[int]int{2: 0, 3: 30},
+			Envs:     map[envName]bool{"prod": false, "dev": true},
+			Extra:    map[string]any{"nested": map[string]any{"y": 2}},
+			Items:    []map[string]int{{"q": 5}},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"HAS KEY", "Labels HAS KEY 'env'", []string{"a"}},
+		{"HAS KEY is case-insensitive", "Labels has key 'OWNER'", []string{"b"}},
+		{"NOT HAS KEY", "NOT Labels HAS KEY 'env'", []string{"b"}},
+		{"HAS KEY int", "Counts HAS KEY 1", []string{"a"}},
+		{"HAS KEY typed string", "Envs HAS KEY 'DEV'", []string{"a", "b"}},
+		{"HAS KEY nested", "Extra.nested HAS KEY 'x'", []string{"a"}},
+		{"HAS KEY in slice", "Items HAS KEY 'r'", []string{"a"}},
+		{"KEYS CONTAINS", "KEYS(Labels) CONTAINS 'tea'", []string{"a"}},
+		{"KEYS =", "KEYS(Labels) = 'owner'", []string{"b"}},
+		{"KEYS set", "KEYS(Labels) CONTAINS ALL ('env', 'team')", []string{"a"}},
+		{"KEYS int", "KEYS(Counts) < 2", []string{"a"}},
+		{"KEYS ordered", "KEYS(Counts) = [2, 3]", []string{"b"}},
+		{"VALUES", "VALUES(Labels) = 'ops'", []string{"b"}},
+		{"VALUES IN", "VALUES(Labels) IN ('core', 'x')", []string{"a"}},
+		{"Wildcard", "Metadata.*.Level = 'senior'", []string{"a"}},
+		{"Wildcard numbers", "Metadata.*.Years > 3", []string{"a", "b"}},
+		{"Wildcard map of any", "Extra.*.y = 2", []string{"b"}},
+		{"Wildcard over slice of maps", "Items.* = 5", []string{"b"}},
+		{"Wildcard picks slice elements", "Items[*].q = 1", []string{"a"}},
+		{"Int key", "Counts.2 = 20", []string{"a"}},
+		{"Int key indexed", "Counts[3] = 30", []string{"b"}},
+		{"Typed string key", "Envs.prod = true", []string{"a"}},
+		{"Fields named Has and Key", "Name = 'a' AND Labels HAS KEY 'team'", []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := Parse(tt.query, records)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.query, err)
+			}
+			checkNames(t, tt.query, results, tt.want)
+		})
+	}
+}
+
+func TestStructWildcard(t *testing.T) {
+	type Scores struct {
+		Math    int
+		Physics int
+		secret  int
+		Hidden  int `parser:"-"`
+	}
+	type Student struct {
+		Name   string
+		Scores Scores
+	}
+	students := []Student{
+		{Name: "a", Scores: Scores{Math: 90, Physics: 40, secret: 100, Hidden: 100}},
+		{Name: "b", Scores: Scores{Math: 50, Physics: 60, secret: 100, Hidden: 100}},
+	}
+
+	results, err := Parse("Scores.* > 80", students)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Name != "a" {
+		t.Errorf("Scores.* > 80 = %v, want [a]", results)
+	}
+
+	results, err = Parse("Scores.* = 100", students)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("wildcard reached hidden fields: %v", results)
+	}
+}
+
+func TestMapAccessControl(t *testing.T) {
+	tests := []struct {
+		query string
+		opts  []Option
+		ok    bool
+	}{
+		{"Metadata.*.Level = 'x'", []Option{WithDeniedFields("Metadata.go.Level")}, false},
+		{"Metadata.*.Level = 'x'", []Option{WithDeniedFields("Metadata.*")}, false},
+		{"Metadata.*.Level = 'x'", []Option{WithDeniedFields("Metadata.go.Years")}, true},
+		{"Metadata.*.Level = 'x'", []Option{WithAllowedFields("Metadata.*")}, true},
+		{"Metadata.*.Level = 'x'", []Option{WithAllowedFields("Metadata.go.Level")}, false},
+		{"VALUES(Labels) = 'x'", []Option{WithDeniedFields("Labels.secret")}, false},
+		{"KEYS(Labels) = 'x'", []Option{WithAllowedFields("Labels")}, false},
+		{"KEYS(Labels) = 'x'", []Option{WithAllowedFields("Labels.*")}, true},
+		{"KEYS(Labels) = 'x'", []Option{WithDeniedFields("Labels.*")}, false},
+		{"KEYS(Labels) = 'x'", []Option{WithDeniedFields("Labels.secret")}, false},
+		{"Labels HAS KEY 'secret'", []Option{WithDeniedFields("Labels.secret")}, false},
+		{"Labels HAS KEY 'env'", []Option{WithDeniedFields("Labels.secret")}, true},
+		{"Labels HAS KEY 'env'", []Option{WithAllowedFields("Labels")}, false},
+		{"Labels HAS KEY 'env'", []Option{WithAllowedFields("Labels", "Labels.*")}, true},
+	}
+	records := []mapRecord{{Name: "a", Labels: map[string]string{"secret": "x"}}}
+	for _, tt := range tests {
+		_, err := Parse(tt.query, records, tt.opts...)
+		if tt.ok && err != nil {
+			t.Errorf("Parse(%q) returned error: %v", tt.query, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrFieldNotAllowed) {
+			t.Errorf("Parse(%q) expected ErrFieldNotAllowed, got %v", tt.query, err)
+		}
+	}
+}
+
+func TestWildcardPathSegments(t *testing.T) {
+	tests := []struct {
+		path string
+		want []pathSegment
+	}{
+		{"Metadata.*.level", []pathSegment{{name: "Metadata"}, {name: "*", wildcard: true}, {name: "level"}}},
+		{"Metadata.`*`", []pathSegment{{name: "Metadata"}, {name: "*"}}},
+		{"KEYS(Labels)", []pathSegment{{name: "Labels"}, {name: "*", keys: true}}},
+		{"Items.values(Labels)", []pathSegment{{name: "Items"}, {name: "Labels"}, {name: "*", wildcard: true}}},
+	}
+	for _, tt := range tests {
+		if got := pathSegments(tt.path); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("pathSegments(%q) = %+v, want %+v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMapKeyMatching(t *testing.T) {
+	type Config struct {
+		Name string
+		Env  map[string]string
+	}
+	configs := []Config{
+		{Name: "mixed", Env: map[string]string{"Env": "upper", "env": "lower", "ENV": "caps"}},
+		{Name: "folded", Env: map[string]string{"Region": "eu", "REGION": "us", "env": "only"}},
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		mode  MapKeyMatching
+		want  []string
+		err   string
+	}{
+		{"Exact key wins", "Env.env = 'lower'", MapKeyFold, []string{"mixed"}, ""},
+		{"Exact key wins over others", "Env.Env = 'upper'", MapKeyFold, []string{"mixed"}, ""},
+		{"Exact only", "Env.ENV = 'caps'", MapKeyExact, []string{"mixed"}, ""},
+		{"Exact only, other case is absent", "Env.Region IS NULL", MapKeyExact, []string{"mixed"}, ""},
+		{"Exact with HAS KEY", "Env HAS KEY 'ENV'", MapKeyExact, []string{"mixed"}, ""},
+		{"Folded HAS KEY", "Env HAS KEY 'ENV'", MapKeyFold, []string{"mixed", "folded"}, ""},
+		{"Strict without exact match", "Env.eNv = 'x'", MapKeyFoldStrict, nil, `ambiguous map key "eNv"`},
+		{"Strict with exact match", "Env.env = 'only'", MapKeyFoldStrict, []string{"folded"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := Parse(tt.query, configs, WithMapKeyMatching(tt.mode))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Parse(%q) error = %v, want it to contain %q", tt.query, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.query, err)
+			}
+			checkNames(t, tt.query, results, tt.want)
+		})
+	}
+
+	// Without an exact match the smallest folded key is used, whatever the map order
+	configs = configs[1:]
+	for i := 0; i < 20; i++ {
+		results, err := Parse("Env.region = 'us'", configs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("expected REGION to be chosen over Region, got %v", results)
+		}
+	}
+}
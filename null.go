@@ -0,0 +1,178 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// isNullValue reports whether v is NULL: a nil pointer, interface, map or slice, a
+// missing value, or a driver.Valuer such as sql.NullString that reports nil. Zero
+// values such as 0, "" and false are not NULL.
+func isNullValue(v reflect.Value) (bool, error) {
+	if !v.IsValid() {
+		return true, nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return true, nil
+		}
+	}
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	_, isNull, err := resolveValuer(v)
+	return isNull, err
+}
+
+// isNullSlice reports whether values, the flattened values of a slice field, are
+// all NULL, as they are for a nil slice
+func isNullSlice(values []reflect.Value) bool {
+	for _, v := range values {
+		if !isMissingValue(v) {
+			return false
+		}
+	}
+	return len(values) > 0
+}
+
+// IsEmptyExpression tests whether a string, slice, map or array field has no
+// elements: Tags IS EMPTY. NULL, including a nil slice or map, is neither empty nor
+// not empty, and a driver.Valuer is tested by the value it holds.
+type IsEmptyExpression struct {
+	Field string
+	Not   bool
+
+	opts *options
+}
+
+// Evaluate for IsEmptyExpression. A value that has no length, such as a number,
+// fails the query as a comparison with the wrong type does.
+func (ee *IsEmptyExpression) Evaluate(item reflect.Value) (bool, error) {
+	leaves, err := lookupLeaves(item, ee.Field, ee.opts)
+	if err != nil {
+		return false, err
+	}
+	var lastError error
+	matched, null := false, false
+	for _, v := range leaves {
+		isNull, err := isNullValue(v)
+		if err != nil {
+			lastError = fmt.Errorf("failed to read value of field '%s': %w", ee.Field, err)
+			continue
+		}
+		if isNull {
+			null = true
+			continue
+		}
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			v = v.Elem()
+		}
+		if v, _, _ = resolveValuer(v); !v.IsValid() {
+			null = true
+			continue
+		}
+		switch v.Kind() {
+		case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+			matched = matched || (v.Len() == 0) != ee.Not
+		default:
+			lastError = fmt.Errorf("field '%s' of type %s has no length for IS EMPTY", ee.Field, v.Type())
+		}
+	}
+	return ee.opts.settle(matched, null, lastError)
+}
+
+// IsZeroExpression tests whether a field holds the zero value of its type, such as
+// 0, "" or false: Age IS ZERO. NULL is not a value, so it is neither zero nor not
+// zero, and a driver.Valuer is tested by the value it holds.
+type IsZeroExpression struct {
+	Field string
+	Not   bool
+
+	opts *options
+}
+
+// Evaluate for IsZeroExpression
+func (ze *IsZeroExpression) Evaluate(item reflect.Value) (bool, error) {
+	leaves, err := lookupLeaves(item, ze.Field, ze.opts)
+	if err != nil {
+		return false, err
+	}
+	var lastError error
+	matched, null := false, false
+	for _, v := range leaves {
+		isNull, err := isNullValue(v)
+		if err != nil {
+			lastError = fmt.Errorf("failed to read value of field '%s': %w", ze.Field, err)
+			continue
+		}
+		if isNull {
+			null = true
+			continue
+		}
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			v = v.Elem()
+		}
+		if v, _, _ = resolveValuer(v); !v.IsValid() {
+			null = true
+			continue
+		}
+		matched = matched || v.IsZero() != ze.Not
+	}
+	return ze.opts.settle(matched, null, lastError)
+}
+
+// parseIs parses what follows IS or IS NOT after field: NULL, EMPTY, ZERO,
+// DISTINCT FROM value, or a type name. EMPTY, ZERO and DISTINCT are read as
+// identifiers, so they remain usable as field names.
+func (p *Parser) parseIs(field string, not bool) Expression {
+	switch {
+	case p.currentTokenIs(NULL):
+		p.nextToken() // consume NULL
+		return &IsNullExpression{Field: field, Not: not, opts: p.opts}
+	case p.atWord("EMPTY"):
+		p.nextToken() // consume EMPTY
+		return &IsEmptyExpression{Field: field, Not: not, opts: p.opts}
+	case p.atWord("ZERO"):
+		p.nextToken() // consume ZERO
+		return &IsZeroExpression{Field: field, Not: not, opts: p.opts}
+	case p.atWord("DISTINCT") && p.peekToken.Type == IDENTIFIER && strings.EqualFold(p.peekToken.Literal, "FROM"):
+		p.nextToken() // consume DISTINCT
+		p.nextToken() // consume FROM
+		return p.parseDistinctFrom(field, not)
+	}
+	return p.parseIsType(field, not)
+}
+
+// parseDistinctFrom parses the value after IS [NOT] DISTINCT FROM: a string,
+// number, parameter, true, false or NULL. A field is distinct from NULL if it is
+// not NULL, and from a value if it is NULL or not equal to it, so the result is
+// never unknown.
+func (p *Parser) parseDistinctFrom(field string, not bool) Expression {
+	if p.currentTokenIs(NULL) {
+		p.nextToken() // consume NULL
+		return &IsNullExpression{Field: field, Not: !not, opts: p.opts}
+	}
+	switch {
+	case p.currentTokenIs(STRING), p.currentTokenIs(NUMBER), p.currentTokenIs(PARAM):
+	case p.atWord("true"), p.atWord("false"):
+	default:
+		// A bare name would be a field, which can't be compared with a field
+		p.errorf("expected a value or NULL after IS DISTINCT FROM, got %q", p.currentToken.Literal)
+		return nil
+	}
+	ce := &ComparisonExpression{Field: field, Operator: NE, Value: p.currentToken.Literal, NullSafe: true, opts: p.opts}
+	if not {
+		ce.Operator = EQ
+	}
+	ce.num = literalNumber(ce.Value)
+	ce.param = p.parameter()
+	p.nextToken() // consume the value
+	return ce
+}
+
+// atWord reports whether the current token is the identifier word, ignoring case
+func (p *Parser) atWord(word string) bool {
+	return p.currentTokenIs(IDENTIFIER) && strings.EqualFold(p.currentToken.Literal, word)
+}
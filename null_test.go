@@ -0,0 +1,153 @@
+package parser
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type tNullable struct {
+	Name    string
+	Age     int
+	Nick    *string
+	Tags    []string
+	Attrs   map[string]string
+	Score   sql.NullInt64
+	Alias   sql.NullString
+	Manager *tNullable
+}
+
+func TestNullSemantics(t *testing.T) {
+	empty, cc := "", "cc"
+	records := []tNullable{
+		{Name: "a"},
+		{Name: "b", Age: 30, Nick: &empty, Tags: []string{}, Attrs: map[string]string{}, Score: sql.NullInt64{Int64: 5, Valid: true}, Alias: sql.NullString{Valid: true}, Manager: &tNullable{Name: "a"}},
+		{Name: "c", Age: 40, Nick: &cc, Tags: []string{"go"}, Attrs: map[string]string{"k": "v"}, Score: sql.NullInt64{Valid: true}, Alias: sql.NullString{String: "c", Valid: true}, Manager: &tNullable{Name: "b"}},
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"Zero is not NULL", "Age IS NULL", nil},
+		{"Empty string is not NULL", "Nick IS NOT NULL", []string{"b", "c"}},
+		{"Nil slice is NULL", "Tags IS NULL", []string{"a"}},
+		{"Valuer NULL", "Score IS NULL", []string{"a"}},
+		{"Below a nil pointer", "Manager.Name IS NULL", []string{"a"}},
+
+		{"IS ZERO", "Age IS ZERO", []string{"a"}},
+		{"IS NOT ZERO", "Age IS NOT ZERO", []string{"b", "c"}},
+		{"NULL is not zero", "Nick IS ZERO", []string{"b"}},
+		{"NULL is not non-zero", "Nick IS NOT ZERO", []string{"c"}},
+		{"Valuer IS ZERO", "Score IS ZERO", []string{"c"}},
+
+		{"IS EMPTY", "Tags IS EMPTY", []string{"b"}},
+		{"IS NOT EMPTY", "Tags IS NOT EMPTY", []string{"c"}},
+		{"Map IS EMPTY", "Attrs IS EMPTY", []string{"b"}},
+		{"String IS EMPTY", "Nick IS EMPTY", []string{"b"}},
+		{"NULL is not empty", "NOT (Tags IS EMPTY) AND NOT (Tags IS NOT EMPTY)", nil},
+		{"Valuer IS EMPTY", "Alias IS EMPTY", []string{"b"}},
+		{"Valuer IS NOT EMPTY", "Alias IS NOT EMPTY", []string{"c"}},
+
+		{"Comparison with NULL", "Manager.Name != 'a'", []string{"c"}},
+		{"NOT unknown", "NOT (Manager.Name = 'a')", []string{"c"}},
+		{"NOT over a nil slice", "NOT (Tags = 'go')", []string{"b"}},
+		{"Unknown OR true", "Manager.Name = 'a' OR Age = 0", []string{"a", "b"}},
+		{"NOT (unknown AND false)", "NOT (Manager.Name = 'a' AND Age = 30)", []string{"a", "c"}},
+		{"NOT (unknown OR true)", "NOT (Manager.Name = 'x' OR Age = 0)", []string{"b", "c"}},
+		{"Set over a nil slice", "NOT (Tags CONTAINS ANY ('go'))", []string{"b"}},
+
+		{"IS DISTINCT FROM", "Manager.Name IS DISTINCT FROM 'a'", []string{"a", "c"}},
+		{"IS NOT DISTINCT FROM", "Manager.Name IS NOT DISTINCT FROM 'a'", []string{"b"}},
+		{"NOT IS DISTINCT FROM", "NOT (Nick IS DISTINCT FROM 'cc')", []string{"c"}},
+		{"IS DISTINCT FROM NULL", "Nick IS DISTINCT FROM NULL", []string{"b", "c"}},
+		{"IS NOT DISTINCT FROM NULL", "Nick IS NOT DISTINCT FROM NULL", []string{"a"}},
+		{"DISTINCT with AND", "Nick IS DISTINCT FROM 'cc' AND Nick IS NOT DISTINCT FROM ''", []string{"b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := Parse(tt.query, records)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.query, err)
+			}
+			checkNames(t, tt.query, results, tt.want)
+		})
+	}
+}
+
+func TestDistinctFromParameter(t *testing.T) {
+	records := []tNullable{{Name: "a"}, {Name: "b", Manager: &tNullable{Name: "a"}}, {Name: "c", Manager: &tNullable{Name: "b"}}}
+	query := "Manager.Name IS DISTINCT FROM ?"
+	q, err := Compile(query)
+	if err != nil {
+		t.Fatalf("Compile returned error: %v", err)
+	}
+	if q, err = q.Bind("b"); err != nil {
+		t.Fatalf("Bind returned error: %v", err)
+	}
+	results, err := Filter(q, records)
+	if err != nil {
+		t.Fatalf("Filter returned error: %v", err)
+	}
+	checkNames(t, query, results, []string{"a", "b"})
+}
+
+func TestDistinctFromBoolean(t *testing.T) {
+	yes := true
+	type flag struct {
+		Name   string
+		Active *bool
+	}
+	flags := []flag{{Name: "a"}, {Name: "b", Active: &yes}}
+	query := "Active IS DISTINCT FROM TRUE"
+	results, err := Parse(query, flags)
+	if err != nil {
+		t.Fatalf("Parse(%q) returned error: %v", query, err)
+	}
+	checkNames(t, query, results, []string{"a"})
+}
+
+func TestNullPredicateErrors(t *testing.T) {
+	records := []tNullable{{Name: "a", Age: 30}}
+	for _, query := range []string{"IS EMPTY", "IS NOT ZERO", "Name IS DISTINCT FROM", "Name IS DISTINCT FROM (1)", "Name IS DISTINCT FROM Nick", "Name IS NOT DISTINCT FROM b"} {
+		if _, err := Parse(query, records); err == nil {
+			t.Errorf("Parse(%q) expected a parse error", query)
+		}
+	}
+	if _, err := Parse("Age IS EMPTY", records); err == nil || !strings.Contains(err.Error(), "has no length") {
+		t.Errorf("expected an error for IS EMPTY on a number, got %v", err)
+	}
+}
+
+func TestAbsentMapKeyIsNull(t *testing.T) {
+	records := []map[string]any{
+		{"name": "a", "labels": map[string]any{"env": "prod"}},
+		{"name": "b", "labels": map[string]any{"team": "core"}},
+		{"name": "c", "labels": map[string]any{"env": nil}},
+	}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"labels.env IS NULL", []string{"b", "c"}},
+		{"labels.env IS NOT NULL", []string{"a"}},
+		{"labels.env IS DISTINCT FROM 'prod'", []string{"b", "c"}},
+		{"labels.env IS NOT DISTINCT FROM 'prod'", []string{"a"}},
+		{"labels.env IS NOT DISTINCT FROM NULL", []string{"b", "c"}},
+		{"labels.env != 'prod'", nil},
+		{"owner IS NULL AND owner.name IS NULL", []string{"a", "b", "c"}},
+	}
+	for _, policy := range []MissingFieldPolicy{MissingFieldStrict, MissingFieldLenient, MissingFieldSkip} {
+		t.Run(fmt.Sprintf("policy %d", policy), func(t *testing.T) {
+			for _, tt := range tests {
+				results, err := Parse(tt.query, records, WithMissingFields(policy))
+				if err != nil {
+					t.Fatalf("Parse(%q) returned error: %v", tt.query, err)
+				}
+				checkNames(t, tt.query, results, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,377 @@
+package parser
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DecimalComparer can be implemented by decimal and fixed-point types so they can be
+// compared against numeric literals without going through float64. CompareDecimal
+// receives the literal exactly as written in the query (commas removed) and returns
+// -1, 0 or +1 like big.Rat.Cmp.
+type DecimalComparer interface {
+	CompareDecimal(literal string) (int, error)
+}
+
+var (
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+	bigRatType   = reflect.TypeOf(big.Rat{})
+
+	decimalComparerType = reflect.TypeOf((*DecimalComparer)(nil)).Elem()
+)
+
+// maxLiteralDigits and maxLiteralExponent bound the numeric literals a query may
+// hold, so a short literal like 1e999999 can't expand into a huge number. Limits
+// can set tighter bounds.
+const (
+	maxLiteralDigits   = 1000
+	maxLiteralExponent = 1000
+)
+
+// isThousandsComma reports whether the comma at s[i] groups the digits of a number,
+// as in 1,000: exactly three digits follow it. Inside a list the caller doesn't ask,
+// since there (1,2) and (1,000,2) hold several values.
+func isThousandsComma(s string, i int) bool {
+	if i+4 > len(s) {
+		return false
+	}
+	for j := i + 1; j <= i+3; j++ {
+		if !isDigit(s[j]) {
+			return false
+		}
+	}
+	return i+4 == len(s) || !isDigit(s[i+4])
+}
+
+// parseNumericLiteral parses a numeric literal exactly. Commas used as thousands
+// separators and scientific notation are accepted.
+func parseNumericLiteral(s string) (*big.Rat, error) {
+	clean := strings.ReplaceAll(strings.TrimSpace(s), ",", "")
+	if literalTooLarge(clean) {
+		return nil, fmt.Errorf("number is too large: %s", s)
+	}
+	r, ok := new(big.Rat).SetString(clean)
+	if !ok || clean == "" || strings.ContainsAny(clean, "/") {
+		return nil, fmt.Errorf("not a valid number: %s", s)
+	}
+	return r, nil
+}
+
+// numericLiteral is a numeric literal parsed exactly, with the built-in forms it
+// is compared in worked out in advance so comparing an item doesn't allocate
+type numericLiteral struct {
+	rat *big.Rat
+
+	// int64 and uint64 hold the largest integer not above the value, if it fits
+	// in them as i64 and u64 record; isInt is set if the value is that integer
+	int64    int64
+	uint64   uint64
+	i64, u64 bool
+	isInt    bool
+
+	// float64 and float32 are the value rounded to each precision. f64 and f32
+	// record whether the literal is how that float is written, as 0.1 is for the
+	// float64 nearest to it, so fields can be compared with the float instead of
+	// the exact value.
+	float64  float64
+	float32  float32
+	f64, f32 bool
+}
+
+// literalNumber returns value parsed as a number, or nil if it isn't one. It is
+// called once when a literal is added to an expression, not for every item.
+func literalNumber(value string) *numericLiteral {
+	r, err := parseNumericLiteral(value)
+	if err != nil {
+		return nil
+	}
+	n := &numericLiteral{rat: r, isInt: r.IsInt()}
+	floor := new(big.Int).Div(r.Num(), r.Denom()) // Euclidean, so rounded down
+	if n.i64 = floor.IsInt64(); n.i64 {
+		n.int64 = floor.Int64()
+	}
+	if n.u64 = floor.IsUint64(); n.u64 {
+		n.uint64 = floor.Uint64()
+	}
+	n.float64, _ = r.Float64()
+	n.float32, _ = r.Float32()
+	n.f64 = writesFloat(r, float64(n.float64), 64)
+	n.f32 = writesFloat(r, float64(n.float32), 32)
+	return n
+}
+
+// writesFloat reports whether r is f, a float of the given bit size, as written
+// in the fewest digits that read back as f
+func writesFloat(r *big.Rat, f float64, bitSize int) bool {
+	if math.IsInf(f, 0) {
+		return false
+	}
+	short, err := parseNumericLiteral(strconv.FormatFloat(f, 'g', -1, bitSize))
+	return err == nil && short.Cmp(r) == 0
+}
+
+// compareInt compares v with the literal, exactly, and only through big.Rat if the
+// literal is beyond the int64 range
+func (n *numericLiteral) compareInt(v int64) int {
+	if !n.i64 {
+		return new(big.Rat).SetInt64(v).Cmp(n.rat)
+	}
+	return n.compareFloor(cmp.Compare(v, n.int64))
+}
+
+// compareUint is compareInt for unsigned integers
+func (n *numericLiteral) compareUint(v uint64) int {
+	switch {
+	case n.rat.Sign() < 0:
+		return 1
+	case !n.u64:
+		return new(big.Rat).SetUint64(v).Cmp(n.rat)
+	}
+	return n.compareFloor(cmp.Compare(v, n.uint64))
+}
+
+// compareFloor turns the comparison of an integer with the literal's floor into
+// one with the literal: an integer equal to the floor of 29.5 is below it
+func (n *numericLiteral) compareFloor(c int) int {
+	if c == 0 && !n.isInt {
+		return -1
+	}
+	return c
+}
+
+// literalTooLarge reports whether a numeric literal exceeds the fixed bounds
+func literalTooLarge(s string) bool {
+	digits, exponent := literalSize(s)
+	return digits > maxLiteralDigits || exponent > maxLiteralExponent
+}
+
+// checkNumber records an error for a numeric literal token over the Limits or the
+// fixed bounds, so it fails the query when it is compiled
+func (p *Parser) checkNumber(tok Token) {
+	digits, exponent := literalSize(tok.Literal)
+	if !p.checkLiteralSize(digits, exponent) {
+		return
+	}
+	if digits > maxLiteralDigits || exponent > maxLiteralExponent {
+		p.errors = append(p.errors, atColumn("number is too large: "+tok.Literal, tok))
+	}
+}
+
+// literalSize returns the number of digits in the mantissa of a numeric literal and
+// the magnitude of its exponent, without parsing its value
+func literalSize(s string) (digits, exponent int) {
+	lower := strings.ToLower(strings.TrimLeft(s, "+-"))
+	mantissa, exp := lower, ""
+	sep := "e"
+	if strings.HasPrefix(lower, "0x") {
+		sep = "p" // hexadecimal, where e is a digit
+	}
+	if i := strings.LastIndex(lower, sep); i >= 0 {
+		mantissa, exp = lower[:i], lower[i+1:]
+	}
+	for _, c := range mantissa {
+		if isDigit(byte(c)) || 'a' <= c && c <= 'f' {
+			digits++
+		}
+	}
+	exp = strings.TrimLeft(strings.TrimLeft(exp, "+-"), "0")
+	if len(exp) > 9 {
+		return digits, math.MaxInt32
+	}
+	for _, c := range exp {
+		if !isDigit(byte(c)) {
+			return digits, 0 // not a number; SetString rejects it
+		}
+		exponent = exponent*10 + int(c-'0')
+	}
+	return digits, exponent
+}
+
+// formatRat renders r as a plain decimal number. Values with a terminating decimal
+// expansion are rendered exactly.
+func formatRat(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+
+	// The expansion terminates if the denominator only has factors 2 and 5
+	den := new(big.Int).Set(r.Denom())
+	two, five := big.NewInt(2), big.NewInt(5)
+	mod := new(big.Int)
+	twos, fives := 0, 0
+	for mod.Mod(den, two).Sign() == 0 {
+		den.Quo(den, two)
+		twos++
+	}
+	for mod.Mod(den, five).Sign() == 0 {
+		den.Quo(den, five)
+		fives++
+	}
+	digits := 20
+	if den.Cmp(big.NewInt(1)) == 0 {
+		digits = max(twos, fives)
+	}
+	return r.FloatString(digits)
+}
+
+// ratToInt64 truncates r towards zero and clamps it to the int64 range
+func ratToInt64(r *big.Rat) int64 {
+	i := new(big.Int).Quo(r.Num(), r.Denom())
+	if !i.IsInt64() {
+		if i.Sign() < 0 {
+			return math.MinInt64
+		}
+		return math.MaxInt64
+	}
+	return i.Int64()
+}
+
+// compareOrdered maps the result of a three-way comparison onto an operator
+func compareOrdered(c int, op TokenType) bool {
+	switch op {
+	case EQ:
+		return c == 0
+	case NE:
+		return c != 0
+	case LT:
+		return c < 0
+	case GT:
+		return c > 0
+	case LE:
+		return c <= 0
+	case GE:
+		return c >= 0
+	}
+	return false
+}
+
+// compareBigValue compares math/big values and DecimalComparer implementations
+// against a numeric literal, lit as parsed by literalNumber. handled is false if
+// fieldValue is none of those.
+func compareBigValue(fieldValue reflect.Value, literal string, lit *numericLiteral, op TokenType) (match bool, handled bool, err error) {
+	if dc, ok := asDecimalComparer(fieldValue); ok {
+		c, err := dc.CompareDecimal(strings.ReplaceAll(literal, ",", ""))
+		if err != nil {
+			return false, true, fmt.Errorf("invalid decimal value '%s': %w", literal, err)
+		}
+		return compareOrdered(c, op), true, nil
+	}
+
+	switch fieldValue.Type() {
+	case bigIntType, bigFloatType, bigRatType:
+	default:
+		return false, false, nil
+	}
+
+	// math/big values keep their state in unexported fields, so they can only be
+	// read through Interface
+	if !fieldValue.CanInterface() {
+		return false, true, fmt.Errorf("cannot read unexported %s value", fieldValue.Type())
+	}
+
+	if lit == nil {
+		_, err := parseNumericLiteral(literal)
+		return false, true, fmt.Errorf("invalid numeric value '%s': %w", literal, err)
+	}
+
+	switch fieldValue.Type() {
+	case bigIntType:
+		v := fieldValue.Interface().(big.Int)
+		return compareOrdered(new(big.Rat).SetInt(&v).Cmp(lit.rat), op), true, nil
+	case bigRatType:
+		v := fieldValue.Interface().(big.Rat)
+		return compareOrdered(v.Cmp(lit.rat), op), true, nil
+	default:
+		v := fieldValue.Interface().(big.Float)
+		if v.IsInf() {
+			return compareOrdered(v.Sign(), op), true, nil
+		}
+		r, _ := v.Rat(nil)
+		return compareOrdered(r.Cmp(lit.rat), op), true, nil
+	}
+}
+
+// asDecimalComparer returns the DecimalComparer implemented by v, or by a pointer to
+// v for types with pointer receivers
+func asDecimalComparer(v reflect.Value) (DecimalComparer, bool) {
+	if !implementsAny(v.Type(), decimalComparerType) {
+		return nil, false
+	}
+	impl, ok := implementation(v, decimalComparerType)
+	if !ok {
+		return nil, false
+	}
+	return impl.(DecimalComparer), true
+}
+
+// isNumericKind reports whether k is one of the built-in integer or float kinds
+func isNumericKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// compareNumeric is the single numeric comparison used by every expression type. It
+// compares int, uint, float, math/big and DecimalComparer values with a literal of any
+// numeric form, mathematically: 30 > 29.5, a uint is never below -1 and a NaN field
+// is unordered (only != matches). lit is literal as parsed by literalNumber, once
+// per query. handled is false if fieldValue is not numeric; an unparsable literal is
+// an error rather than being treated as zero.
+func compareNumeric(fieldValue reflect.Value, literal string, lit *numericLiteral, op TokenType) (match bool, handled bool, err error) {
+	if match, handled, err := compareBigValue(fieldValue, literal, lit, op); handled {
+		return match, true, err
+	}
+	if !isNumericKind(fieldValue.Kind()) {
+		return false, false, nil
+	}
+
+	if lit == nil {
+		switch fieldValue.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return false, true, fmt.Errorf("invalid floating point value '%s'", literal)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return false, true, fmt.Errorf("invalid unsigned integer value '%s'", literal)
+		default:
+			return false, true, fmt.Errorf("invalid integer value '%s'", literal)
+		}
+	}
+
+	switch fieldValue.Kind() {
+	case reflect.Float32, reflect.Float64:
+		fv := fieldValue.Float()
+		if math.IsNaN(fv) {
+			return op == NE, true, nil
+		}
+		if math.IsInf(fv, 0) {
+			if fv > 0 {
+				return compareOrdered(1, op), true, nil
+			}
+			return compareOrdered(-1, op), true, nil
+		}
+		// A literal that is how a float is written, like 0.1, stands for that
+		// float, so it matches a field holding 0.1. Others, like 1e-400 or
+		// 0.1000000001 for a float32, are compared exactly.
+		v, rounded := lit.float64, lit.f64
+		if fieldValue.Kind() == reflect.Float32 {
+			v, rounded = float64(lit.float32), lit.f32
+		}
+		if !rounded {
+			return compareOrdered(new(big.Rat).SetFloat64(fv).Cmp(lit.rat), op), true, nil
+		}
+		return compareOrdered(cmp.Compare(fv, v), op), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return compareOrdered(lit.compareUint(fieldValue.Uint()), op), true, nil
+	default:
+		return compareOrdered(lit.compareInt(fieldValue.Int()), op), true, nil
+	}
+}
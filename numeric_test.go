@@ -0,0 +1,245 @@
+package parser
+
+import (
+	"math"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// cents is a fixed-point money type used to test DecimalComparer
+type cents int64
+
+func (c cents) CompareDecimal(literal string) (int, error) {
+	lit, err := parseNumericLiteral(literal)
+	if err != nil {
+		return 0, err
+	}
+	v := new(big.Rat).SetFrac64(int64(c), 100)
+	return v.Cmp(lit), nil
+}
+
+func TestExactHumanizedNormalization(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Size > 8EiB", "Size > 9223372036854775808"},
+		{"Size > 16EiB", "Size > 18446744073709551616"},
+		{"Count > 1.5E", "Count > 1500000000000000000"},
+		{"Size > 10EB", "Size > 10000000000000000000"},
+		{"Duration > 0.5s", "Duration > 0.5"},
+		{"Price = 12345678901234567890", "Price = 12345678901234567890"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result := normalizeHumanizedValues(tt.input)
+			if result != tt.expected {
+				t.Errorf("normalizeHumanizedValues(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBigNumericComparisons(t *testing.T) {
+	type Account struct {
+		Name    string
+		Size    uint64
+		Balance *big.Int
+		Ratio   big.Float
+		Share   *big.Rat
+		Amount  cents
+	}
+
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	accounts := []Account{
+		{Name: "a", Size: math.MaxUint64, Balance: huge, Ratio: *big.NewFloat(0.25), Share: big.NewRat(1, 3), Amount: 10},
+		{Name: "b", Size: 1 << 62, Balance: big.NewInt(-5), Ratio: *big.NewFloat(2), Share: big.NewRat(1, 2), Amount: 1999},
+		{Name: "c", Size: 10, Balance: nil, Ratio: *big.NewFloat(1), Share: big.NewRat(3, 2), Amount: 30},
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"Size > 8EiB", []string{"a"}},
+		{"Size = 18446744073709551615", []string{"a"}},
+		{"Size < 16EiB", []string{"a", "b", "c"}},
+		{"Size >= 4EiB", []string{"a", "b"}},
+		{"Balance > 1,000,000,000,000,000,000,000", []string{"a"}},
+		{"Balance = 123456789012345678901234567890", []string{"a"}},
+		{"Balance < 0", []string{"b"}},
+		{"Ratio = 0.25", []string{"a"}},
+		{"Ratio >= 1", []string{"b", "c"}},
+		{"Share > 0.5", []string{"c"}},
+		{"Share <= 0.5", []string{"a", "b"}},
+		{"Amount = 0.1", []string{"a"}},
+		{"Amount > 19.98", []string{"b"}},
+		{"Amount = 0.30", []string{"c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, err := Parse(tt.query, accounts)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.query, err)
+			}
+			checkNames(t, tt.query, results, tt.expected)
+		})
+	}
+}
+
+func TestFloatLiteralRounding(t *testing.T) {
+	type Item struct {
+		Price float64
+	}
+	items := []Item{{0.1}, {0.2}, {0.1 + 0.2}}
+
+	results, err := Parse("Price = 0.1", items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Errorf("expected 1 result, got %d", len(results))
+	}
+
+	// Literals that aren't how a float is written compare exactly, rather than
+	// after rounding to the field's precision
+	type Reading struct {
+		Name  string
+		F     float64
+		Level float32
+	}
+	readings := []Reading{{Name: "zero"}, {Name: "tenth", F: 0.1, Level: 0.1}}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"F = 1e-400", nil},
+		{"F < 1e-400", []string{"zero"}},
+		{"F > -1e-400", []string{"zero", "tenth"}},
+		{"Level = 0.1", []string{"tenth"}},
+		{"Level = 0.1000000001", nil},
+		{"Level > 0.1000000001", []string{"tenth"}},
+		{"Level < 1e-50", []string{"zero"}},
+	}
+	for _, tt := range tests {
+		results, err := Parse(tt.query, readings)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", tt.query, err)
+		}
+		checkNames(t, tt.query, results, tt.want)
+	}
+}
+
+func TestCrossKindNumericComparisons(t *testing.T) {
+	type Reading struct {
+		Name  string
+		Age   int
+		Count uint
+		Small uint8
+		Temp  float64
+		Level float32
+		Tags  []int
+	}
+
+	readings := []Reading{
+		{Name: "a", Age: 30, Count: 0, Small: 255, Temp: 21.5, Level: 0.1, Tags: []int{1, 2}},
+		{Name: "b", Age: 29, Count: 5, Small: 1, Temp: math.NaN(), Level: 0.5, Tags: []int{3}},
+		{Name: "c", Age: -3, Count: 10, Small: 0, Temp: math.Inf(1), Level: 1, Tags: []int{0}},
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"Age > 29.5", []string{"a"}},
+		{"Age < 29.5", []string{"b", "c"}},
+		{"Age = 30.0", []string{"a"}},
+		{"Age = 29.9", nil},
+		{"Age >= -3", []string{"a", "b", "c"}},
+		{"Count > -1", []string{"a", "b", "c"}},
+		{"Count = -1", nil},
+		{"Count != -1", []string{"a", "b", "c"}},
+		{"Small >= 255", []string{"a"}},
+		{"Small < 256", []string{"a", "b", "c"}},
+		{"Temp > 20", []string{"a", "c"}},
+		{"Temp = 21.5", []string{"a"}},
+		{"Temp != 21.5", []string{"b", "c"}},
+		{"Temp < 1e300", []string{"a"}},
+		{"Level = 0.1", []string{"a"}},
+		{"Age > -3.5", []string{"a", "b", "c"}},
+		{"Age < -2.5", []string{"c"}},
+		{"Age <= 29", []string{"b", "c"}},
+		{"Age < 9223372036854775808", []string{"a", "b", "c"}},
+		{"Age > -9223372036854775809", []string{"a", "b", "c"}},
+		{"Count > -0.5", []string{"a", "b", "c"}},
+		{"Count <= 4.5", []string{"a"}},
+		{"Count < 18446744073709551616", []string{"a", "b", "c"}},
+		{"Small = 1.0", []string{"b"}},
+		{"ANY(Age) > 29.5", []string{"a"}},
+		{"ANY(Count) = ANY(-1, 5)", []string{"b"}},
+		{"ANY(Temp) > ANY('21', '1e308')", []string{"a", "c"}},
+		{"ANY(Tags) = 3", []string{"b"}},
+		{"ANY(Tags) > 1.5", []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, err := Parse(tt.query, readings)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.query, err)
+			}
+			checkNames(t, tt.query, results, tt.expected)
+		})
+	}
+}
+
+func TestNumericComparisonErrors(t *testing.T) {
+	type Reading struct {
+		Age     int
+		Active  bool
+		Balance *big.Int
+	}
+	readings := []Reading{{Age: 30, Active: true, Balance: big.NewInt(1)}}
+
+	queries := []string{
+		"Age = 'thirty'",
+		"ANY(Age) = ANY('thirty', 'forty')",
+		"ANY(Active) = 'yes'",
+		"Active = 'yes'",
+		"Balance > 'lots'",
+		"Age > 1 AND Age < 'abc'",
+	}
+
+	for _, query := range queries {
+		t.Run(query, func(t *testing.T) {
+			if _, err := Parse(query, readings); err == nil {
+				t.Errorf("Parse(%q) expected an error, got none", query)
+			}
+		})
+	}
+}
+
+func TestNumericLiteralBounds(t *testing.T) {
+	type Reading struct{ Age int }
+	readings := make([]Reading, 200)
+
+	// Literals over the fixed bounds fail to compile instead of being expanded
+	for _, query := range []string{"Age > 1e999999K", "Age > 1e999999", "Age > 1e99999K", "ANY(Age) = ANY(1, 1e5000)", "Age > 1e1000K"} {
+		start := time.Now()
+		if _, err := Parse(query, readings, WithLimits(DefaultLimits)); err == nil {
+			t.Errorf("Parse(%q) expected an error, got none", query)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Parse(%q) took %v", query, elapsed)
+		}
+	}
+
+	// Within the bounds, large literals still compare exactly
+	results, err := Parse("Age < 1e990K AND Age > -1e-999", readings)
+	if err != nil || len(results) != len(readings) {
+		t.Errorf("Parse returned %d results, %v", len(results), err)
+	}
+}
@@ -0,0 +1,114 @@
+package parser
+
+// Option configures how a query is parsed and evaluated. Options are passed to
+// Parse and NewParser.
+type Option func(*options)
+
+type options struct {
+	// jsonTags makes fields resolvable by the name in their `json` tag
+	jsonTags bool
+
+	unexported UnexportedFieldPolicy
+
+	// allowedFields and deniedFields restrict which field paths a query may use
+	allowedFields []string
+	deniedFields  []string
+
+	limits Limits
+
+	// collation applies to every string comparison; fieldCollations, keyed by
+	// lowercased field path, override it per field
+	collation       Collation
+	fieldCollations map[string]Collation
+
+	mapKeys MapKeyMatching
+
+	missingFields MissingFieldPolicy
+
+	// units converts humanized literals; nil means DefaultUnits
+	units *UnitRegistry
+}
+
+// defaultOptions is used by expressions built without options
+var defaultOptions = &options{}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+	return o
+}
+
+// orDefault lets expressions constructed by hand, with a nil options pointer, use the defaults
+func (o *options) orDefault() *options {
+	if o == nil {
+		return defaultOptions
+	}
+	return o
+}
+
+// WithJSONTags resolves struct fields by the name in their json tag, in addition to
+// the Go field name, so a field tagged `json:"created_at"` can be queried as created_at.
+func WithJSONTags() Option {
+	return func(o *options) {
+		o.jsonTags = true
+	}
+}
+
+// UnexportedFieldPolicy decides whether queries can see unexported struct fields.
+type UnexportedFieldPolicy int
+
+const (
+	// UnexportedSkip treats unexported fields as if they did not exist. This is the
+	// default. Exported fields promoted from unexported embedded structs are still
+	// visible, as they are in Go.
+	UnexportedSkip UnexportedFieldPolicy = iota
+
+	// UnexportedRead lets queries read unexported fields. Their values are read
+	// through reflection only: methods such as String or Value are not called on
+	// them, and math/big values can't be compared.
+	UnexportedRead
+)
+
+// WithUnexportedFields sets the policy for unexported struct fields.
+func WithUnexportedFields(policy UnexportedFieldPolicy) Option {
+	return func(o *options) {
+		o.unexported = policy
+	}
+}
+
+// MissingFieldPolicy decides what happens when a predicate can't be evaluated on an
+// item: a field path that doesn't exist on it, such as a field only some of the
+// types in a []Event have, or a value that can't be compared with the query's, such
+// as the string 'abc' with a number. Every kind of predicate applies it alike. A key
+// a map doesn't have is not missing in this sense: it is NULL under every policy.
+type MissingFieldPolicy int
+
+const (
+	// MissingFieldStrict fails the query with an error, whether the predicate is
+	// under AND, OR or NOT. This is the default. Only operands AND and OR need to
+	// decide their result are evaluated, so IS LoginEvent AND IP = '...' doesn't
+	// read IP from other types.
+	MissingFieldStrict MissingFieldPolicy = iota
+
+	// MissingFieldLenient treats the field or value as NULL. A missing field IS
+	// NULL, and a predicate that can't be evaluated is unknown: NOT keeps it
+	// unknown, AND with a false predicate is false, OR with a true one is true, and
+	// an item the whole query is unknown for doesn't match.
+	MissingFieldLenient
+
+	// MissingFieldSkip leaves out the items a predicate can't be evaluated on,
+	// whatever the rest of the query says.
+	MissingFieldSkip
+)
+
+// WithMissingFields sets the policy for fields missing on an item and values that
+// can't be compared.
+func WithMissingFields(policy MissingFieldPolicy) Option {
+	return func(o *options) {
+		o.missingFields = policy
+	}
+}
@@ -1,10 +1,12 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 type TokenType string
@@ -39,13 +41,25 @@ const (
 	UPPER    TokenType = "UPPER"    // UPPER
 	LOWER    TokenType = "LOWER"    // LOWER
 	EXACT    TokenType = "EXACT"    // EXACT
+	IN       TokenType = "IN"       // IN
+	ALL      TokenType = "ALL"      // ALL
+	ONLY     TokenType = "ONLY"     // ONLY
+	OVERLAPS TokenType = "OVERLAPS" // OVERLAPS
+	LBRACKET TokenType = "LBRACKET" // [
+	RBRACKET TokenType = "RBRACKET" // ]
+	PARAM    TokenType = "PARAM"    // ? or :name
 )
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Column  int // column of the token in runes, starting at 1; 0 if unknown
 }
 
+// Expression is a parsed predicate. Evaluate reports whether item matches it. For a
+// predicate that is unknown for item, such as a comparison with NULL, it returns
+// ErrUnknown, which callers evaluating expressions directly should test for with
+// errors.Is and treat as not matching, as Filter does.
 type Expression interface {
 	Evaluate(item reflect.Value) (bool, error)
 }
@@ -55,6 +69,17 @@ type ComparisonExpression struct {
 	Operator TokenType
 	Value    string
 	Function TokenType
+	NullSafe bool // IS [NOT] DISTINCT FROM: Operator is EQ or NE, and NULL is a value
+
+	opts *options
+
+	// param is the placeholder Value comes from, until it is bound; arg is the
+	// Go value bound to it
+	param *parameter
+	arg   any
+
+	// num is Value parsed as a number when the expression is built, or nil
+	num *numericLiteral
 }
 
 // AnyExpression represents an ANY operator that checks if any of the provided values match the field
@@ -62,6 +87,16 @@ type AnyExpression struct {
 	Field    string
 	Operator TokenType
 	Values   []string
+
+	opts *options
+
+	// params and args run parallel to Values, like param and arg in
+	// ComparisonExpression; both are nil when no value came from a parameter
+	params []*parameter
+	args   []any
+
+	// nums holds Values parsed as numbers, nil for those that aren't
+	nums []*numericLiteral
 }
 
 // NotExpression represents a NOT operation on another expression
@@ -78,69 +113,69 @@ type OrExpression struct {
 	Expressions []Expression
 }
 
-func Parse[T any](query string, data []T) (results []T, err error) {
-	// Use the enhanced lexer that supports negative numbers
-	if query == "" {
-		return data, nil
-	}
-
-	// Normalize humanized values in the query
-	query = normalizeHumanizedValues(query)
-
-	l := NewEnhancedLexer(query)
-	p := NewParser(l)
+// Parse compiles query and returns the items of data that match it. It is
+// shorthand for Compile followed by Filter.
+func Parse[T any](query string, data []T, opts ...Option) (results []T, err error) {
+	// Safety net: no query or data should be able to crash the caller
+	defer func() {
+		if r := recover(); r != nil {
+			results = nil
+			err = fmt.Errorf("internal error while evaluating query: %v", r)
+		}
+	}()
 
-	var ast Expression
-	ast, err = p.ParseQuery()
+	q, err := Compile(query, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse query: %w", err)
-	}
-	if len(p.Errors()) > 0 {
-		return nil, fmt.Errorf("parsing errors: %s", strings.Join(p.Errors(), "; "))
+		return nil, err
 	}
-	if ast == nil {
-		return nil, fmt.Errorf("failed to parse query: AST is nil")
-	}
-
-	results = make([]T, 0, len(data))
-
-	for _, item := range data {
-		val := reflect.ValueOf(item)
-		if val.Kind() == reflect.Ptr && val.IsNil() {
-			continue
-		}
-
-		if val.Kind() == reflect.Ptr {
-			val = val.Elem() // Dereference if it's a pointer to a struct
-		}
-
-		if val.Kind() != reflect.Struct {
-			return nil, fmt.Errorf("expected slice of structs, got %s in data", val.Kind())
-		}
-
-		match, err := ast.Evaluate(val)
-		if err != nil {
-			// Return the evaluation error immediately as it's a validation issue
-			return nil, fmt.Errorf("evaluation error: %w", err)
-		}
+	return Filter(q, data)
+}
 
-		if match {
-			results = append(results, item)
+// Enhanced getFieldValue: returns a slice of reflect.Value if a slice is encountered in the path
+func getFieldValues(item reflect.Value, fieldPath string, opts *options) ([]reflect.Value, error) {
+	leaves, err := resolvePath(item, fieldPath, opts)
+	if err != nil {
+		return nil, err
+	}
+	// Flatten any slices at the leaf, and arrays other than values such as UUIDs
+	// that compare by their text form. A nil slice is NULL; an empty one has no values.
+	flat := []reflect.Value{}
+	for _, v := range leaves {
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			flat = append(flat, missingValue)
+		} else if v.Kind() == reflect.Slice || v.Kind() == reflect.Array && !hasTextForm(v.Type()) {
+			for i := 0; i < v.Len(); i++ {
+				flat = append(flat, unwrapInterface(v.Index(i)))
+			}
+		} else {
+			flat = append(flat, v)
 		}
 	}
-
-	return results, nil
+	return flat, nil
 }
 
-// Enhanced getFieldValue: returns a slice of reflect.Value if a slice is encountered in the path
-func getFieldValues(item reflect.Value, fieldPath string) ([]reflect.Value, error) {
-	parts := strings.Split(fieldPath, ".")
+// resolvePath returns the values at the end of fieldPath without flattening slices,
+// for predicates such as IS EMPTY that test the slice itself. A nil pointer or
+// interface on the way, or a key a map doesn't have, is NULL for the rest of the
+// path. errFieldNotFound is only returned if a struct field was looked up and
+// nothing was found.
+func resolvePath(item reflect.Value, fieldPath string, opts *options) ([]reflect.Value, error) {
+	segments := pathSegments(fieldPath)
 	currentValues := []reflect.Value{item}
-	for _, part := range parts {
+	for _, seg := range segments {
+		part := seg.name
 		nextValues := []reflect.Value{}
+		missed := false
 		for _, val := range currentValues {
+			// A missing element is NULL for the rest of the path
+			if isMissingValue(val) {
+				nextValues = append(nextValues, val)
+				continue
+			}
+
 			if val.Kind() == reflect.Ptr {
 				if val.IsNil() {
+					nextValues = append(nextValues, missingValue)
 					continue
 				}
 				val = val.Elem()
@@ -148,12 +183,88 @@ func getFieldValues(item reflect.Value, fieldPath string) ([]reflect.Value, erro
 
 			// Handle interface{} values by getting the underlying value
 			if val.Kind() == reflect.Interface {
-				val = val.Elem()
+				if val = unwrapInterface(val); isMissingValue(val) {
+					nextValues = append(nextValues, val)
+					continue
+				}
 			}
 
-			if val.Kind() == reflect.Slice {
+			if seg.typeName {
+				nextValues = append(nextValues, typeNames(val)...)
+				continue
+			}
+
+			if seg.recursive {
+				found, err := descend(val, seg, opts)
+				if err != nil {
+					return nil, err
+				}
+				if len(found) == 0 {
+					found = append(found, missingValue)
+				}
+				nextValues = append(nextValues, found...)
+				continue
+			}
+
+			// * and KEYS() apply to a map or struct, or to each element of a slice,
+			// as a named segment does. [*] picks the elements of a slice
+			if seg.wildcard || seg.keys {
+				if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+					nextValues = append(nextValues, expandSegment(val, seg, opts)...)
+					continue
+				}
 				for j := 0; j < val.Len(); j++ {
 					elem := val.Index(j)
+					if seg.elements {
+						nextValues = append(nextValues, unwrapInterface(elem))
+						continue
+					}
+					for (elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Interface) && !elem.IsNil() {
+						elem = elem.Elem()
+					}
+					switch elem.Kind() {
+					case reflect.Map, reflect.Struct:
+						nextValues = append(nextValues, expandSegment(elem, seg, opts)...)
+					case reflect.Ptr, reflect.Interface:
+					default:
+						if seg.wildcard {
+							nextValues = append(nextValues, elem)
+						}
+					}
+				}
+				continue
+			}
+
+			if seg.index != nil {
+				// On a map an index is a key, for maps with integer keys
+				if val.Kind() == reflect.Map && !seg.index.span {
+					mapValue, err := mapLookup(val, strconv.Itoa(seg.index.from), opts)
+					if err != nil {
+						return nil, err
+					}
+					if !mapValue.IsValid() {
+						mapValue = missingValue // an absent key is NULL
+					}
+					nextValues = append(nextValues, mapValue)
+					continue
+				}
+				if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+					missed = true
+					continue
+				}
+				elem, ok := seg.index.apply(val)
+				if !ok {
+					elem = missingValue
+				}
+				elem = unwrapInterface(elem)
+				nextValues = append(nextValues, elem)
+				continue
+			}
+
+			if val.Kind() == reflect.Slice || val.Kind() == reflect.Array {
+				for j := 0; j < val.Len(); j++ {
+					// Nil elements, including nulls in a []any, have no fields
+					elem := unwrapInterface(val.Index(j))
 					if elem.Kind() == reflect.Ptr {
 						if elem.IsNil() {
 							continue
@@ -161,15 +272,17 @@ func getFieldValues(item reflect.Value, fieldPath string) ([]reflect.Value, erro
 						elem = elem.Elem()
 					}
 
-					// Handle interface{} values in slices
-					if elem.Kind() == reflect.Interface {
-						elem = elem.Elem()
-					}
-
 					if elem.Kind() == reflect.Struct || elem.Kind() == reflect.Map {
-						field := getFieldByNameCaseInsensitive(elem, part)
+						field, err := getFieldByNameCaseInsensitive(elem, part, opts)
+						if err != nil {
+							return nil, err
+						}
 						if field.IsValid() {
 							nextValues = append(nextValues, field)
+						} else if elem.Kind() == reflect.Map {
+							nextValues = append(nextValues, missingValue)
+						} else {
+							missed = true
 						}
 					} else {
 						nextValues = append(nextValues, elem)
@@ -178,92 +291,135 @@ func getFieldValues(item reflect.Value, fieldPath string) ([]reflect.Value, erro
 				continue
 			}
 			if val.Kind() == reflect.Struct {
-				field := getFieldByNameCaseInsensitive(val, part)
+				field, err := getFieldByNameCaseInsensitive(val, part, opts)
+				if err != nil {
+					return nil, err
+				}
 				if !field.IsValid() {
+					missed = true
 					continue
 				}
 				nextValues = append(nextValues, field)
 				continue
 			}
 			if val.Kind() == reflect.Map {
-				// Handle map traversal
-				if val.Type().Key().Kind() != reflect.String {
-					// Only string keys can be accessed by field path
-					continue
+				// Handle map traversal, matching string keys as set by WithMapKeyMatching
+				mapValue, err := mapLookup(val, part, opts)
+				if err != nil {
+					return nil, err
 				}
-
-				// Try to find the key case-insensitively
-				mapValue := getMapValue(val, part)
-				if mapValue.IsValid() {
-					nextValues = append(nextValues, mapValue)
+				if !mapValue.IsValid() {
+					mapValue = missingValue // an absent key is NULL
 				}
+				nextValues = append(nextValues, mapValue)
 				continue
 			}
 			// For non-struct, non-slice, non-map, just append (should only happen at leaf)
 			nextValues = append(nextValues, val)
 		}
 		currentValues = nextValues
-		if len(currentValues) == 0 {
-			return nil, fmt.Errorf("field %q not found in path %q", part, fieldPath)
-		}
-	}
-	// Flatten any slices at the leaf
-	flat := []reflect.Value{}
-	for _, v := range currentValues {
-		if v.Kind() == reflect.Slice {
-			for i := 0; i < v.Len(); i++ {
-				flat = append(flat, v.Index(i))
+		if len(currentValues) == 0 && missed {
+			if seg.index != nil {
+				part = seg.index.String()
 			}
-		} else {
-			flat = append(flat, v)
+			return nil, fmt.Errorf("%w: %q in path %q", errFieldNotFound, part, fieldPath)
 		}
 	}
-	return flat, nil
+	return currentValues, nil
 }
 
-// getFieldByNameCaseInsensitive returns the struct field with a name matching 'name' (case-insensitive), or an invalid reflect.Value if not found
-func getFieldByNameCaseInsensitive(val reflect.Value, name string) reflect.Value {
-	// If it's a map with string keys, use our getMapValue helper
-	if val.Kind() == reflect.Map && val.Type().Key().Kind() == reflect.String {
-		return getMapValue(val, name)
-	}
+// lookupField resolves a field path for an expression. A missing field is reported
+// as "field 'X' not found" as the MissingFieldPolicy says, or, with
+// MissingFieldLenient, returned as NULL. An empty slice has no values.
+func lookupField(item reflect.Value, fieldPath string, opts *options) ([]reflect.Value, error) {
+	fieldValues, err := getFieldValues(item, fieldPath, opts)
+	return missingField(fieldValues, err, fieldPath, opts)
+}
+
+// lookupLeaves is lookupField without flattening slices at the leaf
+func lookupLeaves(item reflect.Value, fieldPath string, opts *options) ([]reflect.Value, error) {
+	leaves, err := resolvePath(item, fieldPath, opts)
+	return missingField(leaves, err, fieldPath, opts)
+}
 
-	// Otherwise, for structs, match field names case-insensitively
-	typeOfVal := val.Type()
-	for i := 0; i < typeOfVal.NumField(); i++ {
-		field := typeOfVal.Field(i)
-		if strings.EqualFold(field.Name, name) {
-			return val.Field(i)
+// missingField applies the MissingFieldPolicy if the lookup of fieldPath failed
+// with errFieldNotFound
+func missingField(values []reflect.Value, err error, fieldPath string, opts *options) ([]reflect.Value, error) {
+	if errors.Is(err, errFieldNotFound) {
+		if opts.lenient() {
+			return []reflect.Value{missingValue}, nil
 		}
+		return nil, opts.fail(fmt.Errorf("field '%s' not found", fieldPath))
 	}
-	return reflect.Value{}
+	return values, err
+}
+
+// getFieldByNameCaseInsensitive returns the struct field with a name matching 'name' (case-insensitive), or an invalid reflect.Value if not found.
+// Struct fields can also be matched by their `parser` tag and, with WithJSONTags, their `json` tag.
+func getFieldByNameCaseInsensitive(val reflect.Value, name string, opts *options) (reflect.Value, error) {
+	// Maps are looked up by key
+	if val.Kind() == reflect.Map {
+		return mapLookup(val, name, opts)
+	}
+
+	// Otherwise, for structs, use the cached name table for this type
+	return cachedStructFields(val.Type(), opts.orDefault()).lookup(val, name)
 }
 
 // The core Evaluate method for ComparisonExpression
 func (ce *ComparisonExpression) Evaluate(item reflect.Value) (bool, error) {
-	fieldValues, err := getFieldValues(item, ce.Field)
-	if err != nil || len(fieldValues) == 0 {
-		return false, fmt.Errorf("field '%s' not found", ce.Field)
+	fieldValues, err := lookupField(item, ce.Field, ce.opts)
+	if err != nil {
+		return false, err
 	}
 
+	// A null-safe comparison tests equality and negates it for IS DISTINCT FROM
+	cmp := ce
+	if ce.NullSafe {
+		eq := *ce
+		eq.Operator = EQ
+		cmp = &eq
+	}
+
+	// The field matches if any of its values does. A NULL value leaves the result
+	// unknown if no other value matched. A value that can't be compared, such as a
+	// number in a []any of strings, is passed over if another value could be;
+	// otherwise the failure is settled as the MissingFieldPolicy says.
 	var lastError error
+	matched, null, compared := false, false, false
 	for _, fieldValue := range fieldValues {
-		match, err := ce.compareValue(fieldValue)
+		isNull, err := isNullValue(fieldValue)
+		if err != nil {
+			lastError = fmt.Errorf("failed to read value of field '%s': %w", ce.Field, err)
+			continue
+		}
+		if isNull {
+			null = true
+			continue
+		}
+		match, err := cmp.compareValue(fieldValue)
 		if err != nil {
 			lastError = err
 			continue // Try other values if this one fails
 		}
+		compared = true
 		if match {
-			return true, nil
+			matched = true
+			break
 		}
 	}
-
-	// If we had errors and no successful matches, return the last error
-	if lastError != nil {
-		return false, lastError
+	if compared {
+		lastError = nil
 	}
-
-	return false, nil
+	if ce.NullSafe {
+		// NULL is distinct from every value, so it doesn't leave the result unknown
+		match, err := ce.opts.settle(matched, false, lastError)
+		if err != nil {
+			return false, err
+		}
+		return match == (ce.Operator == EQ), nil
+	}
+	return ce.opts.settle(matched, null, lastError)
 }
 
 // compareValue handles the actual comparison for a single value
@@ -274,116 +430,55 @@ func (ce *ComparisonExpression) compareValue(fieldValue reflect.Value) (bool, er
 		}
 		fieldValue = fieldValue.Elem()
 	}
-	switch fieldValue.Kind() {
-	case reflect.String:
-		s := fieldValue.Interface().(string)
-		val := ce.Value
-		switch ce.Function {
-		case UPPER:
-			s = strings.ToUpper(s)
-			val = strings.ToUpper(val)
-		case LOWER:
-			s = strings.ToLower(s)
-			val = strings.ToLower(val)
-		case EXACT:
-			// No change, direct comparison
-		default:
-			// Default behavior is case-insensitive for EQ, NE, CONTAINS
-			if ce.Operator == EQ || ce.Operator == NE || ce.Operator == CONTAINS {
-				s = strings.ToLower(s)
-				val = strings.ToLower(val)
+
+	// driver.Valuer types (sql.NullString, sql.NullInt64, ...) compare by the value
+	// they hold; Valid=false is NULL and matches nothing
+	fieldValue, isNull, err := resolveValuer(fieldValue)
+	if err != nil {
+		return false, fmt.Errorf("failed to read value of field '%s': %w", ce.Field, err)
+	}
+	if isNull {
+		return false, nil
+	}
+
+	// Bound times and durations compare by their Go value
+	if match, handled := compareBound(fieldValue, ce.arg, ce.Operator); handled {
+		return match, nil
+	}
+
+	// Enums with a String method can be compared by name, e.g. Status = 'Active'
+	if isNumericKind(fieldValue.Kind()) {
+		if ce.num == nil {
+			if s, ok, err := textValue(fieldValue); ok {
+				if err != nil {
+					return false, fmt.Errorf("failed to read value of field '%s': %w", ce.Field, err)
+				}
+				return ce.compareString(s), nil
 			}
 		}
+	}
 
-		switch ce.Operator {
-		case EQ:
-			return s == val, nil
-		case NE:
-			return s != val, nil
-		case LT:
-			return s < val, nil
-		case GT:
-			return s > val, nil
-		case LE:
-			return s <= val, nil
-		case GE:
-			return s >= val, nil
-		case CONTAINS:
-			return strings.Contains(s, val), nil
-		}
-	case reflect.Bool:
-		b, _ := strconv.ParseBool(ce.Value)
-		switch ce.Operator {
-		case EQ:
-			return fieldValue.Interface().(bool) == b, nil
-		case NE:
-			return fieldValue.Interface().(bool) != b, nil
-		}
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		// Remove any commas from the number string (common with large numbers)
-		cleanValue := strings.ReplaceAll(ce.Value, ",", "")
-		v, err := strconv.ParseInt(cleanValue, 10, 64)
-		if err != nil {
-			return false, fmt.Errorf("invalid integer value '%s' for comparison with field '%s': %w", ce.Value, ce.Field, err)
-		}
-		fv := fieldValue.Int()
-		switch ce.Operator {
-		case EQ:
-			return fv == v, nil
-		case NE:
-			return fv != v, nil
-		case LT:
-			return fv < v, nil
-		case GT:
-			return fv > v, nil
-		case LE:
-			return fv <= v, nil
-		case GE:
-			return fv >= v, nil
-		}
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		// Remove any commas from the number string (common with large numbers)
-		cleanValue := strings.ReplaceAll(ce.Value, ",", "")
-		v, err := strconv.ParseUint(cleanValue, 10, 64)
+	// Numbers of any kind, including math/big and decimal types, share one comparison
+	if match, handled, err := compareNumeric(fieldValue, ce.Value, ce.num, ce.Operator); handled {
 		if err != nil {
-			return false, fmt.Errorf("invalid unsigned integer value '%s' for comparison with field '%s': %w", ce.Value, ce.Field, err)
+			return false, fmt.Errorf("%w for comparison with field '%s'", err, ce.Field)
 		}
-		fv := fieldValue.Uint()
-		switch ce.Operator {
-		case EQ:
-			return fv == v, nil
-		case NE:
-			return fv != v, nil
-		case LT:
-			return fv < v, nil
-		case GT:
-			return fv > v, nil
-		case LE:
-			return fv <= v, nil
-		case GE:
-			return fv >= v, nil
-		}
-	case reflect.Float32, reflect.Float64:
-		// Remove any commas from the number string (common with large numbers)
-		cleanValue := strings.ReplaceAll(ce.Value, ",", "")
-		v, err := strconv.ParseFloat(cleanValue, 64)
+		return match, nil
+	}
+
+	switch fieldValue.Kind() {
+	case reflect.String:
+		return ce.compareString(fieldValue.String()), nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(ce.Value)
 		if err != nil {
-			return false, fmt.Errorf("invalid floating point value '%s' for comparison with field '%s': %w", ce.Value, ce.Field, err)
+			return false, fmt.Errorf("invalid boolean value '%s' for comparison with field '%s'", ce.Value, ce.Field)
 		}
-		fv := fieldValue.Float()
 		switch ce.Operator {
 		case EQ:
-			return fv == v, nil
+			return fieldValue.Bool() == b, nil
 		case NE:
-			return fv != v, nil
-		case LT:
-			return fv < v, nil
-		case GT:
-			return fv > v, nil
-		case LE:
-			return fv <= v, nil
-		case GE:
-			return fv >= v, nil
+			return fieldValue.Bool() != b, nil
 		}
 	case reflect.Slice:
 		if fieldValue.IsNil() {
@@ -397,11 +492,11 @@ func (ce *ComparisonExpression) compareValue(fieldValue reflect.Value) (bool, er
 					item = item.Elem()
 				}
 				if item.Kind() == reflect.String {
-					if item.String() == ce.Value || strings.Contains(item.String(), ce.Value) {
+					if ce.elementMatches(item.String(), true) {
 						return true, nil
 					}
 				} else if item.Kind() == reflect.Interface {
-					if s, ok := item.Interface().(string); ok && (s == ce.Value || strings.Contains(s, ce.Value)) {
+					if elem := item.Elem(); elem.Kind() == reflect.String && ce.elementMatches(elem.String(), true) {
 						return true, nil
 					}
 				}
@@ -414,11 +509,11 @@ func (ce *ComparisonExpression) compareValue(fieldValue reflect.Value) (bool, er
 					item = item.Elem()
 				}
 				if item.Kind() == reflect.String {
-					if item.String() == ce.Value {
+					if ce.elementMatches(item.String(), false) {
 						return true, nil
 					}
 				} else if item.Kind() == reflect.Interface {
-					if s, ok := item.Interface().(string); ok && s == ce.Value {
+					if elem := item.Elem(); elem.Kind() == reflect.String && ce.elementMatches(elem.String(), false) {
 						return true, nil
 					}
 				}
@@ -431,21 +526,84 @@ func (ce *ComparisonExpression) compareValue(fieldValue reflect.Value) (bool, er
 					item = item.Elem()
 				}
 				if item.Kind() == reflect.String {
-					if item.String() == ce.Value {
+					if ce.elementMatches(item.String(), false) {
 						return false, nil
 					}
 				} else if item.Kind() == reflect.Interface {
-					if s, ok := item.Interface().(string); ok && s == ce.Value {
+					if elem := item.Elem(); elem.Kind() == reflect.String && ce.elementMatches(elem.String(), false) {
 						return false, nil
 					}
 				}
 			}
 			return true, nil
 		}
+	case reflect.Struct, reflect.Array:
+		// IDs, timestamps and other types with a text form compare as strings
+		if s, ok, err := textValue(fieldValue); ok {
+			if err != nil {
+				return false, fmt.Errorf("failed to read value of field '%s': %w", ce.Field, err)
+			}
+			return ce.compareString(s), nil
+		}
 	}
 	return false, nil
 }
 
+// elementMatches reports whether an element of a nested string slice equals, or
+// with contains holds, the value. Elements compare byte-wise unless a collation is set.
+func (ce *ComparisonExpression) elementMatches(s string, contains bool) bool {
+	val := ce.Value
+	if c, ok := ce.opts.orDefault().collationFor(ce.Field); ok {
+		s, val = c.key(s), c.key(val)
+	}
+	if contains {
+		return strings.Contains(s, val)
+	}
+	return s == val
+}
+
+// compareString compares a string field value, applying the case function or the
+// default case-insensitivity of =, != and CONTAINS
+func (ce *ComparisonExpression) compareString(s string) bool {
+	val := ce.Value
+	switch ce.Function {
+	case UPPER:
+		s = strings.ToUpper(s)
+		val = strings.ToUpper(val)
+	case LOWER:
+		s = strings.ToLower(s)
+		val = strings.ToLower(val)
+	case EXACT:
+		// No change, direct comparison
+	default:
+		if c, ok := ce.opts.orDefault().collationFor(ce.Field); ok {
+			s, val = c.key(s), c.key(val)
+		} else if ce.Operator == EQ || ce.Operator == NE || ce.Operator == CONTAINS {
+			// Default behavior is case-insensitive for EQ, NE, CONTAINS
+			s = strings.ToLower(s)
+			val = strings.ToLower(val)
+		}
+	}
+
+	switch ce.Operator {
+	case EQ:
+		return s == val
+	case NE:
+		return s != val
+	case LT:
+		return s < val
+	case GT:
+		return s > val
+	case LE:
+		return s <= val
+	case GE:
+		return s >= val
+	case CONTAINS:
+		return strings.Contains(s, val)
+	}
+	return false
+}
+
 // Evaluate for ConjunctionExpression
 func (ce *ConjunctionExpression) Evaluate(item reflect.Value) (bool, error) {
 	if len(ce.Expressions) == 0 {
@@ -465,7 +623,7 @@ func (ce *ConjunctionExpression) Evaluate(item reflect.Value) (bool, error) {
 			break
 		}
 		cmp, ok := expr.(*ComparisonExpression)
-		if !ok {
+		if !ok || cmp.NullSafe {
 			allCmp = false
 			break
 		}
@@ -479,70 +637,121 @@ func (ce *ConjunctionExpression) Evaluate(item reflect.Value) (bool, error) {
 
 	// Special case for AND conditions on the same field
 	if allCmp {
-		fieldValues, err := getFieldValues(item, field)
+		fieldValues, err := lookupField(item, field, ce.Expressions[0].(*ComparisonExpression).opts)
 		if err != nil || len(fieldValues) == 0 {
-			return false, fmt.Errorf("field '%s' not found", field)
+			return false, err
 		}
 
+		unknown := false
 		if fieldValues[0].Kind() == reflect.Slice {
 			for i := 0; i < fieldValues[0].Len(); i++ {
-				elem := fieldValues[0].Index(i)
-				allTrue := true
-				for _, expr := range ce.Expressions {
-					cmp := expr.(*ComparisonExpression)
-					if match, _ := cmp.compareValue(elem); !match {
-						allTrue = false
-						break
-					}
-				}
-				if allTrue {
+				allTrue, err := ce.matchesAll(fieldValues[0].Index(i))
+				switch {
+				case errors.Is(err, ErrUnknown):
+					unknown = true
+				case err != nil:
+					return false, err
+				case allTrue:
 					return true, nil
 				}
 			}
+			if unknown {
+				return false, ErrUnknown
+			}
 			return false, nil
 		} else {
 			// For scalar values, check all conditions against each value
 			for _, val := range fieldValues {
-				allTrue := true
-				for _, expr := range ce.Expressions {
-					cmp := expr.(*ComparisonExpression)
-					if match, _ := cmp.compareValue(val); !match {
-						allTrue = false
-						break
-					}
-				}
-				if !allTrue {
+				allTrue, err := ce.matchesAll(val)
+				switch {
+				case errors.Is(err, ErrUnknown):
+					unknown = true
+				case err != nil:
+					return false, err
+				case !allTrue:
 					return false, nil
 				}
 			}
+			if unknown {
+				return false, ErrUnknown
+			}
 			return true, nil
 		}
 	}
 
-	// Fallback: for AND over different fields, all must be true for the same item
+	// Fallback: for AND over different fields, all must be true for the same item.
+	// A false operand decides the result even after an unknown one.
+	unknown := false
 	for _, expr := range ce.Expressions {
 		if expr == nil {
 			return false, nil
 		}
 		match, err := expr.Evaluate(item)
-		if err != nil {
+		switch {
+		case errors.Is(err, ErrUnknown):
+			unknown = true
+		case err != nil:
 			return false, err
+		case !match:
+			return false, nil
 		}
-		if !match {
+	}
+	if unknown {
+		return false, ErrUnknown
+	}
+	return true, nil
+}
+
+// matchesAll reports whether val satisfies every comparison of ce, which are all on
+// the same field. Failures are settled as in ComparisonExpression, and a false
+// comparison decides the result even after an unknown one.
+func (ce *ConjunctionExpression) matchesAll(val reflect.Value) (bool, error) {
+	unknown := false
+	for _, expr := range ce.Expressions {
+		cmp := expr.(*ComparisonExpression)
+		isNull, err := isNullValue(val)
+		match := false
+		if err != nil {
+			err = fmt.Errorf("failed to read value of field '%s': %w", cmp.Field, err)
+		} else if !isNull {
+			match, err = cmp.compareValue(val)
+		}
+		if err != nil || !match {
+			match, err = cmp.opts.settle(match, isNull, err)
+		}
+		switch {
+		case errors.Is(err, ErrUnknown):
+			unknown = true
+		case err != nil:
+			return false, err
+		case !match:
 			return false, nil
 		}
 	}
+	if unknown {
+		return false, ErrUnknown
+	}
 	return true, nil
 }
 
-// Evaluate for OrExpression
+// Evaluate for OrExpression. A true operand decides the result, so the operands
+// after it are not evaluated; otherwise an error fails the OR as it would an AND.
 func (oe *OrExpression) Evaluate(item reflect.Value) (bool, error) {
+	unknown := false
 	for _, expr := range oe.Expressions {
 		match, err := expr.Evaluate(item)
-		if err == nil && match {
+		switch {
+		case errors.Is(err, ErrUnknown):
+			unknown = true
+		case err != nil:
+			return false, err
+		case match:
 			return true, nil
 		}
 	}
+	if unknown {
+		return false, ErrUnknown
+	}
 	return false, nil
 }
 
@@ -551,249 +760,188 @@ func (oe *OrExpression) Evaluate(item reflect.Value) (bool, error) {
 type IsNullExpression struct {
 	Field string
 	Not   bool
+
+	opts *options
 }
 
+// Evaluate for IsNullExpression. Only nil pointers, interfaces, maps and slices,
+// and Valuers reporting nil, are NULL; zero values and empty slices are not, see
+// IS ZERO and IS EMPTY. IS NULL is never unknown.
 func (e *IsNullExpression) Evaluate(item reflect.Value) (bool, error) {
-	fieldValues, err := getFieldValues(item, e.Field)
-	if err != nil || len(fieldValues) == 0 {
-		return false, fmt.Errorf("field '%s' not found", e.Field)
-	}
-	for _, v := range fieldValues {
-		if v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
-			if v.IsNil() {
-				return !e.Not, nil
-			}
-		} else if v.Kind() == reflect.Slice {
-			if v.IsNil() || v.Len() == 0 {
-				return !e.Not, nil
-			}
-		} else if !v.IsValid() {
-			return !e.Not, nil
-		} else if v.IsZero() {
+	leaves, err := lookupLeaves(item, e.Field, e.opts)
+	if err != nil {
+		return false, err
+	}
+	for _, v := range leaves {
+		isNull, err := isNullValue(v)
+		if err != nil {
+			return false, fmt.Errorf("failed to read value of field '%s': %w", e.Field, err)
+		}
+		if isNull {
 			return !e.Not, nil
 		}
 	}
-	return e.Not, nil // IS NOT NULL: true if found and not nil/zero
+	return e.Not, nil // IS NOT NULL: true if found and not nil
 }
 
 // Evaluate for AnyExpression
 func (ae *AnyExpression) Evaluate(item reflect.Value) (bool, error) {
-	fieldValues, err := getFieldValues(item, ae.Field)
-	if err != nil || len(fieldValues) == 0 {
-		return false, fmt.Errorf("field '%s' not found", ae.Field)
+	fieldValues, err := lookupField(item, ae.Field, ae.opts)
+	if err != nil {
+		return false, err
 	}
 
-	// For each field value, check if any of the values match
+	// For each field value, check if any of the values match. A field value only
+	// fails if it can't be compared with any of them, so the list can mix types,
+	// and is then passed over as in ComparisonExpression if another value could be.
+	var lastError error
+	matched, null, comparedAny := false, false, false
 	for _, fieldValue := range fieldValues {
-		// For each value in the ANY() list, check if it matches
-		for _, value := range ae.Values {
-			match, _ := ae.compareValue(fieldValue, value)
+		isNull, err := isNullValue(fieldValue)
+		if err != nil {
+			lastError = fmt.Errorf("failed to read value of field '%s': %w", ae.Field, err)
+			continue
+		}
+		if isNull {
+			null = true
+			continue
+		}
+		var valueError error
+		compared := false
+		for i, value := range ae.Values {
+			match, err := ae.compareValue(fieldValue, value, ae.nums[i], ae.arg(i))
+			if err != nil {
+				valueError = err
+				continue
+			}
+			compared = true
 			if match {
-				return true, nil
+				matched = true
+				break
 			}
 		}
+		if !compared && valueError != nil {
+			lastError = valueError
+		}
+		comparedAny = comparedAny || compared
+		if matched {
+			break
+		}
 	}
-	return false, nil
+	if comparedAny {
+		lastError = nil
+	}
+	return ae.opts.settle(matched, null, lastError)
+}
+
+// compareString compares a string field value with a single ANY value
+func (ae *AnyExpression) compareString(s string, value string) bool {
+	// ANY is case-sensitive unless a collation is set
+	if c, ok := ae.opts.orDefault().collationFor(ae.Field); ok {
+		s, value = c.key(s), c.key(value)
+	}
+	switch ae.Operator {
+	case EQ:
+		return s == value
+	case NE:
+		return s != value
+	case LT:
+		return s < value
+	case GT:
+		return s > value
+	case LE:
+		return s <= value
+	case GE:
+		return s >= value
+	case CONTAINS:
+		return strings.Contains(s, value)
+	}
+	return false
 }
 
 // compareValue handles the actual comparison for a single value against a single ANY value
-func (ae *AnyExpression) compareValue(fieldValue reflect.Value, value string) (bool, error) {
+func (ae *AnyExpression) compareValue(fieldValue reflect.Value, value string, num *numericLiteral, arg any) (bool, error) {
 	if fieldValue.Kind() == reflect.Ptr {
 		if fieldValue.IsNil() {
 			return false, nil
 		}
 		fieldValue = fieldValue.Elem()
 	}
+	if fieldValue.Kind() == reflect.Interface {
+		if fieldValue.IsNil() {
+			return false, nil
+		}
+		fieldValue = fieldValue.Elem()
+	}
+
+	fieldValue, isNull, err := resolveValuer(fieldValue)
+	if err != nil {
+		return false, fmt.Errorf("failed to read value of field '%s': %w", ae.Field, err)
+	}
+	if isNull {
+		return false, nil
+	}
+
+	if match, handled := compareBound(fieldValue, arg, ae.Operator); handled {
+		return match, nil
+	}
+
+	if isNumericKind(fieldValue.Kind()) {
+		if num == nil {
+			if s, ok, err := textValue(fieldValue); ok {
+				if err != nil {
+					return false, fmt.Errorf("failed to read value of field '%s': %w", ae.Field, err)
+				}
+				return ae.compareString(s, value), nil
+			}
+		}
+	}
+
+	if match, handled, err := compareNumeric(fieldValue, value, num, ae.Operator); handled {
+		if err != nil {
+			return false, fmt.Errorf("%w for comparison with field '%s'", err, ae.Field)
+		}
+		return match, nil
+	}
 
 	switch fieldValue.Kind() {
 	case reflect.String:
-		s := fieldValue.Interface().(string)
-		switch ae.Operator {
-		case EQ:
-			return s == value, nil
-		case NE:
-			return s != value, nil
-		case LT:
-			return s < value, nil
-		case GT:
-			return s > value, nil
-		case LE:
-			return s <= value, nil
-		case GE:
-			return s >= value, nil
-		case CONTAINS:
-			return strings.Contains(s, value), nil
+		return ae.compareString(fieldValue.String(), value), nil
+	case reflect.Struct, reflect.Array:
+		if s, ok, err := textValue(fieldValue); ok {
+			if err != nil {
+				return false, fmt.Errorf("failed to read value of field '%s': %w", ae.Field, err)
+			}
+			return ae.compareString(s, value), nil
 		}
 	case reflect.Bool:
-		b, _ := strconv.ParseBool(value)
-		switch ae.Operator {
-		case EQ:
-			return fieldValue.Interface().(bool) == b, nil
-		case NE:
-			return fieldValue.Interface().(bool) != b, nil
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, fmt.Errorf("invalid boolean value '%s' for comparison with field '%s'", value, ae.Field)
 		}
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		v, _ := strconv.ParseInt(value, 10, 64)
-		fv := fieldValue.Int()
-		switch ae.Operator {
-		case EQ:
-			return fv == v, nil
-		case NE:
-			return fv != v, nil
-		case LT:
-			return fv < v, nil
-		case GT:
-			return fv > v, nil
-		case LE:
-			return fv <= v, nil
-		case GE:
-			return fv >= v, nil
-		}
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		v, _ := strconv.ParseUint(value, 10, 64)
-		fv := fieldValue.Uint()
 		switch ae.Operator {
 		case EQ:
-			return fv == v, nil
+			return fieldValue.Bool() == b, nil
 		case NE:
-			return fv != v, nil
-		case LT:
-			return fv < v, nil
-		case GT:
-			return fv > v, nil
-		case LE:
-			return fv <= v, nil
-		case GE:
-			return fv >= v, nil
-		}
-	case reflect.Float32, reflect.Float64:
-		v, _ := strconv.ParseFloat(value, 64)
-		fv := fieldValue.Float()
-		switch ae.Operator {
-		case EQ:
-			return fv == v, nil
-		case NE:
-			return fv != v, nil
-		case LT:
-			return fv < v, nil
-		case GT:
-			return fv > v, nil
-		case LE:
-			return fv <= v, nil
-		case GE:
-			return fv >= v, nil
+			return fieldValue.Bool() != b, nil
 		}
 	case reflect.Slice:
 		// For a slice field, check if the value exists in the slice
 		if fieldValue.IsNil() {
 			return false, nil
 		}
+		var lastError error
 		for i := 0; i < fieldValue.Len(); i++ {
-			item := fieldValue.Index(i)
-			if item.Kind() == reflect.Ptr && !item.IsNil() {
-				item = item.Elem()
+			match, err := ae.compareValue(fieldValue.Index(i), value, num, arg)
+			if err != nil {
+				lastError = err
+				continue
 			}
-
-			if item.Kind() == reflect.String {
-				switch ae.Operator {
-				case EQ:
-					if item.String() == value {
-						return true, nil
-					}
-				case NE:
-					if item.String() != value {
-						return true, nil
-					}
-				case CONTAINS:
-					if strings.Contains(item.String(), value) {
-						return true, nil
-					}
-				}
-			} else if item.Kind() == reflect.Int || item.Kind() == reflect.Int8 ||
-				item.Kind() == reflect.Int16 || item.Kind() == reflect.Int32 ||
-				item.Kind() == reflect.Int64 {
-				v, err := strconv.ParseInt(value, 10, 64)
-				if err != nil {
-					return false, nil
-				}
-				itemVal := item.Int()
-				switch ae.Operator {
-				case EQ:
-					if itemVal == v {
-						return true, nil
-					}
-				case NE:
-					if itemVal != v {
-						return true, nil
-					}
-				case LT:
-					if itemVal < v {
-						return true, nil
-					}
-				case GT:
-					if itemVal > v {
-						return true, nil
-					}
-				case LE:
-					if itemVal <= v {
-						return true, nil
-					}
-				case GE:
-					if itemVal >= v {
-						return true, nil
-					}
-				}
-			} else if item.Kind() == reflect.Float32 || item.Kind() == reflect.Float64 {
-				v, err := strconv.ParseFloat(value, 64)
-				if err != nil {
-					return false, nil
-				}
-				itemVal := item.Float()
-				switch ae.Operator {
-				case EQ:
-					if itemVal == v {
-						return true, nil
-					}
-				case NE:
-					if itemVal != v {
-						return true, nil
-					}
-				case LT:
-					if itemVal < v {
-						return true, nil
-					}
-				case GT:
-					if itemVal > v {
-						return true, nil
-					}
-				case LE:
-					if itemVal <= v {
-						return true, nil
-					}
-				case GE:
-					if itemVal >= v {
-						return true, nil
-					}
-				}
-			} else if item.Kind() == reflect.Interface {
-				if s, ok := item.Interface().(string); ok {
-					switch ae.Operator {
-					case EQ:
-						if s == value {
-							return true, nil
-						}
-					case NE:
-						if s != value {
-							return true, nil
-						}
-					case CONTAINS:
-						if strings.Contains(s, value) {
-							return true, nil
-						}
-					}
-				}
+			if match {
+				return true, nil
 			}
 		}
+		return false, lastError
 	}
 	return false, nil
 }
@@ -803,15 +951,32 @@ type LexerInterface interface {
 }
 
 type Parser struct {
-	l LexerInterface
+	l    LexerInterface
+	opts *options
 
 	currentToken Token
 	peekToken    Token
 	errors       []string
+
+	// accessErr is the first field rejected by the allow/deny options
+	accessErr error
+
+	// positional counts the ? parameters seen so far
+	positional int
+
+	// scope is the path of the ANY/ALL quantifiers being parsed, which field paths
+	// inside them are relative to
+	scope string
+
+	// Counters for Limits; limitErr is the first limit exceeded
+	tokens     int
+	depth      int
+	predicates int
+	limitErr   error
 }
 
-func NewParser(l LexerInterface) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+func NewParser(l LexerInterface, opts ...Option) *Parser {
+	p := &Parser{l: l, opts: newOptions(opts), errors: []string{}}
 	p.nextToken()
 	p.nextToken()
 	return p
@@ -823,12 +988,53 @@ func (p *Parser) Errors() []string {
 
 func (p *Parser) nextToken() {
 	p.currentToken = p.peekToken
+	if p.limitErr != nil {
+		p.peekToken = Token{Type: EOF}
+		return
+	}
 	p.peekToken = p.l.NextToken()
+	if p.peekToken.Type != EOF {
+		p.tokens++
+		if max := p.opts.orDefault().limits.MaxTokens; max > 0 && p.tokens > max {
+			p.failLimit(exceeded(ErrTooManyTokens, max))
+			return
+		}
+	}
 
 	// If the peek token is ILLEGAL, record the error
 	if p.peekToken.Type == ILLEGAL {
 		p.errors = append(p.errors, p.peekToken.Literal)
 	}
+	if p.peekToken.Type == NUMBER {
+		p.checkNumber(p.peekToken)
+	}
+}
+
+// errorf records a parse error at the column of the current token
+func (p *Parser) errorf(format string, args ...any) {
+	p.errors = append(p.errors, atColumn(fmt.Sprintf(format, args...), p.currentToken))
+}
+
+// atColumn adds the column tok starts at to an error message about it, if the
+// lexer reported one
+func atColumn(msg string, tok Token) string {
+	if tok.Column == 0 {
+		return msg
+	}
+	return fmt.Sprintf("%s (column %d)", msg, tok.Column)
+}
+
+// checkField records an error if the options don't allow querying field
+func (p *Parser) checkField(field string) bool {
+	path := joinPath(p.scope, field)
+	if err := p.opts.orDefault().checkFieldAccess(path, canonicalPath(path)); err != nil {
+		p.errors = append(p.errors, err.Error())
+		if p.accessErr == nil {
+			p.accessErr = err
+		}
+		return false
+	}
+	return true
 }
 
 func (p *Parser) currentTokenIs(t TokenType) bool {
@@ -861,17 +1067,23 @@ func (p *Parser) ParseQuery() (Expression, error) {
 
 	// Check for unexpected trailing RPAREN tokens after parsing the main expression
 	if p.currentToken.Type == RPAREN {
-		p.errors = append(p.errors, "unbalanced parenthesis: unexpected closing )")
+		p.errorf("unbalanced parenthesis: unexpected closing )")
 		// Skip any trailing RPAREN tokens
 		for p.currentToken.Type == RPAREN {
 			p.nextToken()
 		}
 	}
 	if p.currentToken.Type != EOF && len(p.errors) == 0 {
-		p.errors = append(p.errors, "unexpected token after end of query")
+		p.errorf("unexpected token after end of query")
 	}
 	// We'll handle this specific case in the compareValue method
 
+	if p.limitErr != nil {
+		return nil, p.limitErr
+	}
+	if p.accessErr != nil {
+		return nil, p.accessErr
+	}
 	if len(p.errors) > 0 {
 		return nil, fmt.Errorf("%s", strings.Join(p.errors, "; "))
 	}
@@ -886,7 +1098,7 @@ func (p *Parser) parseOrExpression() Expression {
 		right := p.parseAndExpression()
 		if right == nil {
 			// If there's an error in the right side, stop parsing this expression
-			p.errors = append(p.errors, "invalid expression after OR")
+			p.errorf("invalid expression after OR")
 			return expr
 		}
 		if orExpr, ok := expr.(*OrExpression); ok {
@@ -913,7 +1125,7 @@ func (p *Parser) parseAndExpression() Expression {
 		right := p.parsePrimary()
 		if right == nil {
 			// If there's an error in the right side, stop parsing this expression
-			p.errors = append(p.errors, "invalid expression after AND")
+			p.errorf("invalid expression after AND")
 			return expr
 		}
 		if andExpr, ok := expr.(*ConjunctionExpression); ok {
@@ -930,17 +1142,40 @@ func (p *Parser) parseAndExpression() Expression {
 }
 
 func (p *Parser) parsePrimary() Expression {
+	if !p.enterNesting() {
+		return nil
+	}
+	defer p.leaveNesting()
+
 	// Handle NOT operator
 	if p.currentTokenIs(NOT) {
 		p.nextToken() // consume NOT
 		expr := p.parsePrimary()
 		if expr == nil {
-			p.errors = append(p.errors, "invalid expression after NOT")
+			p.errorf("invalid expression after NOT")
 			return nil
 		}
 		return &NotExpression{Expression: expr}
 	}
 
+	// IS TypeName and IS NOT TypeName test the type of the item itself, which
+	// inside a quantifier reads the quantifier's field
+	if p.currentTokenIs(IS) {
+		if !p.checkField("") || !p.countPredicate() {
+			return nil
+		}
+		p.nextToken() // consume IS
+		not := p.currentTokenIs(NOT)
+		if not {
+			p.nextToken() // consume NOT
+		}
+		if p.atWord("EMPTY") || p.atWord("ZERO") || p.atWord("DISTINCT") {
+			p.errorf("expected a field before IS %s", strings.ToUpper(p.currentToken.Literal))
+			return nil
+		}
+		return p.parseIsType("", not)
+	}
+
 	if p.currentTokenIs(LPAREN) {
 		// We're starting a parenthesized expression
 		p.nextToken()
@@ -955,7 +1190,7 @@ func (p *Parser) parsePrimary() Expression {
 		expr := p.parseOrExpression() // Use parseOrExpression for full precedence inside parens
 
 		if expr == nil {
-			p.errors = append(p.errors, "invalid expression inside parentheses")
+			p.errorf("invalid expression inside parentheses")
 			// Skip to matching parenthesis or EOF
 			for !p.currentTokenIs(EOF) && !p.currentTokenIs(RPAREN) {
 				p.nextToken()
@@ -972,10 +1207,10 @@ func (p *Parser) parsePrimary() Expression {
 			// We can no longer check the last character of the input
 			if p.currentToken.Type == EOF {
 				// Just assume there's a missing closing parenthesis
-				p.errors = append(p.errors, "unbalanced parenthesis: missing closing parenthesis at end of input")
+				p.errorf("unbalanced parenthesis: missing closing parenthesis at end of input")
 			}
 
-			p.errors = append(p.errors, "unbalanced parenthesis: missing closing )")
+			p.errorf("unbalanced parenthesis: missing closing )")
 			return nil // Return nil to prevent cascading errors
 		}
 		return expr
@@ -987,22 +1222,25 @@ func (p *Parser) parsePrimary() Expression {
 
 		// Expect left parenthesis
 		if !p.currentTokenIs(LPAREN) {
-			p.errors = append(p.errors, "expected '(' after ANY")
+			p.errorf("expected '(' after ANY")
 			return nil
 		}
 		p.nextToken() // Move past (
 
 		// Read field name
 		if !p.currentTokenIs(IDENTIFIER) {
-			p.errors = append(p.errors, "expected field name inside ANY()")
+			p.errorf("expected field name inside ANY()")
 			return nil
 		}
 		field := p.currentToken.Literal
+		if !p.checkField(field) || !p.countPredicate() {
+			return nil
+		}
 		p.nextToken() // Move past field name
 
 		// Expect right parenthesis
 		if !p.currentTokenIs(RPAREN) {
-			p.errors = append(p.errors, "expected ')' after field name in ANY()")
+			p.errorf("expected ')' after field name in ANY()")
 			return nil
 		}
 		p.nextToken() // Move past )
@@ -1012,7 +1250,7 @@ func (p *Parser) parsePrimary() Expression {
 		case EQ, NE, LT, GT, LE, GE, CONTAINS:
 			operator = p.currentToken.Type
 		default:
-			p.errors = append(p.errors, "expected comparison operator (=, !=, <, >, <=, >=, CONTAINS) after ANY()")
+			p.errorf("expected comparison operator (=, !=, <, >, <=, >=, CONTAINS) after ANY()")
 			return nil
 		}
 		p.nextToken() // Move past operator
@@ -1020,63 +1258,38 @@ func (p *Parser) parsePrimary() Expression {
 		// Expect ANY values
 		if !p.currentTokenIs(ANY) {
 			// Handle simple case for single value comparison: ANY(field) = 'value'
-			if p.currentTokenIs(STRING) || p.currentTokenIs(NUMBER) {
+			if p.currentTokenIs(STRING) || p.currentTokenIs(NUMBER) || p.currentTokenIs(PARAM) {
 				ae := &AnyExpression{
 					Field:    field,
 					Operator: operator,
-					Values:   []string{p.currentToken.Literal},
+					opts:     p.opts,
 				}
+				ae.addValue(p.currentToken.Literal, p.parameter())
 				p.nextToken() // Move past value
 				return ae
 			}
 
-			p.errors = append(p.errors, "expected ANY() for values or a direct value")
+			p.errorf("expected ANY() for values or a direct value")
 			return nil
 		}
 		p.nextToken() // Move past ANY
 
 		// Expect left parenthesis for values
 		if !p.currentTokenIs(LPAREN) {
-			p.errors = append(p.errors, "expected '(' after ANY")
+			p.errorf("expected '(' after ANY")
 			return nil
 		}
 		p.nextToken() // Move past (
 
-		// Parse value list
-		values := []string{}
-
-		// Read the first value
-		if !p.currentTokenIs(STRING) && !p.currentTokenIs(NUMBER) {
-			p.errors = append(p.errors, "expected string or number value in ANY()")
-			return nil
-		}
-		values = append(values, p.currentToken.Literal)
-		p.nextToken() // Move past first value
-
-		// Read additional values if present
-		for p.currentTokenIs(COMMA) {
-			p.nextToken() // Move past comma
-
-			if !p.currentTokenIs(STRING) && !p.currentTokenIs(NUMBER) {
-				p.errors = append(p.errors, "expected string or number value after comma in ANY()")
-				return nil
-			}
-			values = append(values, p.currentToken.Literal)
-			p.nextToken() // Move past value
-		}
-
-		// Expect right parenthesis to close values
-		if !p.currentTokenIs(RPAREN) {
-			p.errors = append(p.errors, "expected ')' after values in ANY()")
-			return nil
-		}
-		p.nextToken() // Move past )
-
-		return &AnyExpression{
+		ae := &AnyExpression{
 			Field:    field,
 			Operator: operator,
-			Values:   values,
+			opts:     p.opts,
 		}
+		if !p.parseValueList(ae, "ANY()", RPAREN) {
+			return nil
+		}
+		return ae
 	}
 
 	if p.currentTokenIs(IDENTIFIER) || p.currentTokenIs(UPPER) || p.currentTokenIs(LOWER) || p.currentTokenIs(EXACT) {
@@ -1088,29 +1301,83 @@ func (p *Parser) parsePrimary() Expression {
 			p.nextToken() // consume function
 
 			if !p.currentTokenIs(LPAREN) {
-				p.errors = append(p.errors, "expected '(' after function name")
+				p.errorf("expected '(' after function name")
 				return nil
 			}
 			p.nextToken() // consume '('
 
 			if !p.currentTokenIs(IDENTIFIER) {
-				p.errors = append(p.errors, "expected field name in function call")
+				p.errorf("expected field name in function call")
 				return nil
 			}
 			field = p.currentToken.Literal
+			if !p.checkField(field) || !p.countPredicate() {
+				return nil
+			}
 			p.nextToken() // consume field
 
 			if !p.currentTokenIs(RPAREN) {
-				p.errors = append(p.errors, "expected ')' after field name in function call")
+				p.errorf("expected ')' after field name in function call")
 				return nil
 			}
 			p.nextToken() // consume ')'
 		} else {
 			field = p.currentToken.Literal
-			p.nextToken()
+
+			// Field ANY (predicate) / Field ALL (predicate)
+			if p.peekToken.Type == ANY || p.peekToken.Type == ALL {
+				if !p.checkField(field) || !p.countPredicate() {
+					return nil
+				}
+				p.nextToken() // consume field
+				return p.parseQuantifier(field)
+			}
+
+			// KEYS(Field) and VALUES(Field) read the keys or values of a map
+			if p.peekToken.Type == LPAREN && isPathFunction(field) {
+				if field = p.parsePathFunction(); field == "" {
+					return nil
+				}
+			} else {
+				p.nextToken()
+			}
+			if !p.checkField(field) || !p.countPredicate() {
+				return nil
+			}
+		}
+
+		// Field IN (...) is shorthand for ANY(Field) = ANY(...)
+		if p.currentTokenIs(IN) || (p.currentTokenIs(NOT) && p.peekToken.Type == IN) {
+			not := p.currentTokenIs(NOT)
+			if not {
+				p.nextToken() // consume NOT
+			}
+			p.nextToken() // consume IN
+			if !p.currentTokenIs(LPAREN) {
+				p.errorf("expected '(' after IN")
+				return nil
+			}
+			p.nextToken() // consume '('
+			ae := &AnyExpression{Field: field, Operator: EQ, opts: p.opts}
+			if !p.parseValueList(ae, "IN()", RPAREN) {
+				return nil
+			}
+			if not {
+				return &NotExpression{Expression: ae}
+			}
+			return ae
 		}
 
-		// Handle IS NULL / IS NOT NULL
+		if p.atHasKey() {
+			return p.parseHasKey(field)
+		}
+
+		// Set operators compare the whole slice: CONTAINS ALL (...), OVERLAPS, = [...]
+		if function == "" && p.atSetOperator() {
+			return p.parseSetExpression(field)
+		}
+
+		// IS [NOT] NULL, EMPTY, ZERO, DISTINCT FROM value or TypeName
 		if p.currentTokenIs(IS) {
 			p.nextToken()
 			not := false
@@ -1118,18 +1385,12 @@ func (p *Parser) parsePrimary() Expression {
 				not = true
 				p.nextToken()
 			}
-			if p.currentTokenIs(NULL) {
-				p.nextToken()
-				return &IsNullExpression{Field: field, Not: not}
-			} else {
-				p.errors = append(p.errors, "expected NULL after IS")
-				return nil
-			}
+			return p.parseIs(field, not)
 		}
 
 		expr, err := p.parseComparisonWithField(field)
 		if err != nil {
-			p.errors = append(p.errors, err.Error())
+			p.errorf("%s", err)
 			return nil
 		}
 		expr.Function = function
@@ -1138,14 +1399,54 @@ func (p *Parser) parsePrimary() Expression {
 
 	// If we get here, it's an unexpected token
 	if !p.currentTokenIs(EOF) {
-		p.errors = append(p.errors, fmt.Sprintf("unexpected token: %s", p.currentToken.Literal))
+		p.errorf("unexpected token: %s", p.currentToken.Literal)
 		p.nextToken() // Skip over this token to try to continue parsing
 	}
 	return nil
 }
 
+// parseValueList reads the values of an ANY(...) or IN (...) list into ae, up to and
+// including the closing parenthesis, or bracket for [...] lists. The opening one has
+// been consumed.
+func (p *Parser) parseValueList(ae *AnyExpression, name string, closing TokenType) bool {
+	// Read the first value
+	if !p.currentTokenIs(STRING) && !p.currentTokenIs(NUMBER) && !p.currentTokenIs(PARAM) {
+		p.errorf("expected string or number value in %s", name)
+		return false
+	}
+	ae.addValue(p.currentToken.Literal, p.parameter())
+	p.nextToken() // Move past first value
+
+	// Read additional values if present
+	for p.currentTokenIs(COMMA) {
+		p.nextToken() // Move past comma
+
+		if !p.currentTokenIs(STRING) && !p.currentTokenIs(NUMBER) && !p.currentTokenIs(PARAM) {
+			p.errorf("expected string or number value after comma in %s", name)
+			return false
+		}
+		ae.addValue(p.currentToken.Literal, p.parameter())
+		if !p.checkListSize(len(ae.Values)) {
+			return false
+		}
+		p.nextToken() // Move past value
+	}
+
+	// Expect right parenthesis to close values
+	if !p.currentTokenIs(closing) {
+		if closing == RBRACKET {
+			p.errorf("expected ']' after values in %s", name)
+		} else {
+			p.errorf("expected ')' after values in %s", name)
+		}
+		return false
+	}
+	p.nextToken() // Move past )
+	return true
+}
+
 func (p *Parser) parseComparisonWithField(field string) (*ComparisonExpression, error) {
-	expr := &ComparisonExpression{Field: field}
+	expr := &ComparisonExpression{Field: field, opts: p.opts}
 
 	switch p.currentToken.Type {
 	case EQ, NE, LT, GT, GE, LE, CONTAINS:
@@ -1158,6 +1459,8 @@ func (p *Parser) parseComparisonWithField(field string) (*ComparisonExpression,
 
 	// Get the value
 	expr.Value = p.currentToken.Literal
+	expr.num = literalNumber(expr.Value)
+	expr.param = p.parameter()
 
 	// Check if there's an identifier right after a number (e.g. "25abc") which would indicate an invalid number
 	if p.currentToken.Type == NUMBER && p.peekToken.Type == IDENTIFIER {
@@ -1337,8 +1640,8 @@ func (l *Lexer) readString() string {
 }
 
 // newToken creates a new Token.
-func newToken(tokenType TokenType, ch byte) Token {
-	return Token{Type: tokenType, Literal: string(ch)}
+func newToken[T byte | rune](tokenType TokenType, ch T) Token {
+	return Token{Type: tokenType, Literal: string(rune(ch))}
 }
 
 func isLetter(ch byte) bool {
@@ -1371,6 +1674,14 @@ func LookupIdentifier(identifier string) TokenType {
 		return LOWER
 	case "EXACT":
 		return EXACT
+	case "IN":
+		return IN
+	case "ALL":
+		return ALL
+	case "ONLY":
+		return ONLY
+	case "OVERLAPS":
+		return OVERLAPS
 	default:
 		return IDENTIFIER
 	}
@@ -1382,11 +1693,11 @@ func (ne *NotExpression) Evaluate(item reflect.Value) (bool, error) {
 	if cmp, ok := ne.Expression.(*ComparisonExpression); ok {
 		// When NOT is used with EXACT, we want to ensure the EXACT logic is preserved.
 		if cmp.Function == EXACT {
-			// Temporarily remove the function to force exact comparison, then negate it.
-			originalFunction := cmp.Function
-			cmp.Function = ""
-			result, err := cmp.Evaluate(item)
-			cmp.Function = originalFunction // Restore for other evaluations
+			// Evaluate a copy without the function to force exact comparison, then
+			// negate it. The tree is not modified, so it can be shared between goroutines.
+			plain := *cmp
+			plain.Function = ""
+			result, err := plain.Evaluate(item)
 			if err != nil {
 				return false, err
 			}
@@ -1401,127 +1712,173 @@ func (ne *NotExpression) Evaluate(item reflect.Value) (bool, error) {
 	return !result, nil
 }
 
-// getMapValue returns the map value for a key (case-insensitive match)
-func getMapValue(mapValue reflect.Value, key string) reflect.Value {
-	// If it's a map with string keys, try to find a case-insensitive key match
-	if mapValue.Kind() == reflect.Map && mapValue.Type().Key().Kind() == reflect.String {
-		for _, mapKey := range mapValue.MapKeys() {
-			if strings.EqualFold(mapKey.String(), key) {
-				value := mapValue.MapIndex(mapKey)
-
-				// Handle interface{} values
-				if value.Kind() == reflect.Interface {
-					value = value.Elem()
+// getMapValue returns the map value for a string key: the exact key if there is one,
+// otherwise, unless mode is MapKeyExact, a key that matches case-insensitively
+func getMapValue(mapValue reflect.Value, key string, mode MapKeyMatching) (reflect.Value, error) {
+	value := mapValue.MapIndex(reflect.ValueOf(key).Convert(mapValue.Type().Key()))
+	if !value.IsValid() && mode != MapKeyExact {
+		// Several keys can differ only in case; the smallest wins, so the
+		// result doesn't depend on map iteration order
+		var found, other string
+		folded := foldKey(key)
+		for iter := mapValue.MapRange(); iter.Next(); {
+			mapKey := iter.Key().String()
+			if foldKey(mapKey) != folded {
+				continue
+			}
+			if !value.IsValid() || mapKey < found {
+				if value.IsValid() {
+					other = found
 				}
-
-				return value
+				value, found = iter.Value(), mapKey
+			} else {
+				other = mapKey
 			}
 		}
+		if other != "" && mode == MapKeyFoldStrict {
+			return reflect.Value{}, fmt.Errorf("ambiguous map key %q: matches %q and %q", key, found, other)
+		}
 	}
-	return reflect.Value{}
+	return unwrapInterface(value), nil
 }
 
 // normalizeHumanizedValues processes a query string and converts humanized values
 // (like "1.5K", "2.3MB") back to their original numeric values
 func normalizeHumanizedValues(query string) string {
+	normalized, _ := normalizeQuery(query, DefaultUnits)
+	return normalized
+}
+
+// normalizeQuery is normalizeHumanizedValues that also returns, for each byte of the
+// result and for its end, the offset in query it came from, so errors can point at
+// what the user typed. A converted value maps to where it starts. Units are looked up
+// in units.
+func normalizeQuery(query string, units *UnitRegistry) (string, []int) {
 	if query == "" {
-		return query
+		return query, []int{0}
 	}
 
 	var result strings.Builder
+	offsets := make([]int, 0, len(query)+1)
+	// write appends s, copied from query at from, or converted from the value there
+	write := func(s string, from int, copied bool) {
+		result.WriteString(s)
+		for k := range len(s) {
+			if copied {
+				offsets = append(offsets, from+k)
+			} else {
+				offsets = append(offsets, from)
+			}
+		}
+	}
 	i := 0
+	var lists valueLists
 
 	for i < len(query) {
 		// Handle quoted strings - pass them through as-is
-		if query[i] == '\'' {
-			// Find the end of the quoted string
-			start := i
-			i++ // Skip opening quote
-
-			for i < len(query) && query[i] != '\'' {
-				// Handle escaped quotes
-				if query[i] == '\\' && i+1 < len(query) && query[i+1] == '\'' {
-					i += 2
-				} else {
-					i++
-				}
-			}
+		if query[i] == '\'' || query[i] == '"' {
+			_, end, _ := scanString(query, i)
+			write(query[i:end], i, true)
+			i = end
+			continue
+		}
 
-			if i < len(query) {
-				i++ // Skip closing quote
+		// Quoted identifier segments are field names, not values, as are the
+		// indexes and keys in brackets after a name
+		if query[i] == '`' || query[i] == '[' && i > 0 && continuesIdentifier(query[i-1]) {
+			end := i + 1
+			if query[i] == '`' {
+				_, end, _ = scanBacktick(query, i)
+			} else if bracketEnd, err := scanBracket(query, i); err == nil {
+				end = bracketEnd
+			}
+			if query[i] == '`' || end > i+1 {
+				write(query[i:end], i, true)
+				i = end
+				lists.add(IDENTIFIER)
+				continue
 			}
-
-			// Write the entire quoted string as-is
-			result.WriteString(query[start:i])
-			continue
 		}
 
 		// Check if we're at the start of a potential humanized number
-		if isLetterOrDigit(query[i]) || query[i] == '.' {
+		if r, _ := utf8.DecodeRuneInString(query[i:]); isLetterRune(r) || isDigitRune(r) || r == '.' {
 			tokenStart := i
 
-			// Read the token (identifier or number-like)
-			for i < len(query) && (isLetterOrDigit(query[i]) || query[i] == '.' || query[i] == ',') {
-				i++
+			// Read the token (identifier or number-like). Numbers may also carry
+			// symbols used by registered unit suffixes, e.g. "50%"
+			isNumber := isDigit(query[i]) || query[i] == '.'
+			for i < len(query) {
+				r, width := utf8.DecodeRuneInString(query[i:])
+				if !(isLetterRune(r) || isDigitRune(r) || r == '.' ||
+					(r == ',' && isNumber && !lists.inList() && isThousandsComma(query, i)) ||
+					(isNumber && units.isSuffixChar(r))) {
+					break
+				}
+				i += width
 			}
 
 			token := query[tokenStart:i]
-			// Try to parse as time duration (e.g., "1s", "10m", "2h", "1d", "1y")
-			// Only try this if the token contains letters (indicating a unit suffix)
-			if containsLetters(token) {
-				if seconds, err := parseTimeDuration(token); err == nil {
-					result.WriteString(fmt.Sprintf("%d", seconds))
-					continue
-				}
+			if isNumber {
+				lists.add(NUMBER)
+			} else {
+				lists.add(LookupIdentifier(token))
 			}
 
-			// Try to parse as humanized byte size (e.g., "10GB", "1.5MB", "10KB", "1KiB")
-			// Only try this if the token contains letters (indicating a unit suffix)
-			if containsLetters(token) {
-				if bytes, err := parseByteSize(token); err == nil {
-					result.WriteString(fmt.Sprintf("%d", bytes))
+			// Try the registered unit families (time, bytes, SI and any custom ones)
+			// in precedence order, e.g. "10m", "1.5GiB", "2.5K"
+			if isNumber {
+				// The value is written out exactly so large values like 16EiB
+				// don't lose precision or overflow
+				if num, ok := units.lookup(token); ok {
+					write(formatRat(num), tokenStart, false)
 					continue
 				}
 			}
 
-			// Try to parse as humanized number with SI prefix (e.g., "1.5K", "2.3M")
-			if parsedFloat, err := parseHumanizedNumber(token); err == nil {
-				// Check if it's a whole number
-				if parsedFloat == float64(int64(parsedFloat)) {
-					result.WriteString(fmt.Sprintf("%d", int64(parsedFloat)))
-				} else {
-					result.WriteString(fmt.Sprintf("%g", parsedFloat))
-				}
-				continue
-			}
-
 			// Try to parse comma-separated numbers (e.g., "1,000", "1,234,567")
 			if parsedInt, err := parseCommaSeparatedNumber(token); err == nil {
-				result.WriteString(fmt.Sprintf("%d", parsedInt))
+				write(strconv.FormatInt(parsedInt, 10), tokenStart, false)
 				continue
 			}
 
 			// If not a humanized value, write the token as-is
-			result.WriteString(token)
+			write(token, tokenStart, true)
 			continue
 		}
 
 		// For any other character, just copy it
-		result.WriteByte(query[i])
+		switch query[i] {
+		case '(':
+			lists.add(LPAREN)
+		case ')':
+			lists.add(RPAREN)
+		case '[':
+			lists.add(LBRACKET)
+		case ']':
+			lists.add(RBRACKET)
+		case '<':
+			lists.add(LT)
+		case '>':
+			lists.add(GT)
+		case '=':
+			if i == 0 || query[i-1] != '<' && query[i-1] != '>' {
+				lists.add(EQ) // or the end of !=, which opens lists too
+			}
+		case ',':
+			lists.add(COMMA)
+		}
+		write(query[i:i+1], i, true)
 		i++
 	}
 
-	return result.String()
+	offsets = append(offsets, len(query))
+	return result.String(), offsets
 }
 
-// containsLetters checks if a string contains any alphabetic characters
-func containsLetters(s string) bool {
-	for _, r := range s {
-		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
-			return true
-		}
-	}
-	return false
+// continuesIdentifier reports whether a bracket after ch indexes the field path
+// ch ends, as in Scores[1:3] or Labels['team'], rather than opening a list
+func continuesIdentifier(ch byte) bool {
+	return isLetterOrDigit(ch) || ch == '`' || ch == ']' || ch == '*' || ch >= utf8.RuneSelf
 }
 
 // isLetterOrDigit checks if a character is a letter or digit
@@ -1529,40 +1886,6 @@ func isLetterOrDigit(ch byte) bool {
 	return isLetter(ch) || isDigit(ch)
 }
 
-// parseHumanizedNumber parses numbers with SI prefixes (K, M, G, T, etc.)
-func parseHumanizedNumber(s string) (float64, error) {
-	s = strings.TrimSpace(s)
-	if s == "" {
-		return 0, fmt.Errorf("empty string")
-	}
-
-	// Check for SI suffixes (uppercase only to avoid conflicts with time units)
-	suffixes := map[string]float64{
-		"K": 1e3,
-		"M": 1e6,
-		"G": 1e9,
-		"T": 1e12,
-		"P": 1e15,
-		"E": 1e18,
-	}
-
-	for suffix, multiplier := range suffixes {
-		if strings.HasSuffix(s, suffix) {
-			numStr := strings.TrimSuffix(s, suffix)
-			if num, err := strconv.ParseFloat(numStr, 64); err == nil {
-				return num * multiplier, nil
-			}
-		}
-	}
-
-	// Try to parse as regular number
-	if num, err := strconv.ParseFloat(s, 64); err == nil {
-		return num, nil
-	}
-
-	return 0, fmt.Errorf("not a valid humanized number: %s", s)
-}
-
 // parseCommaSeparatedNumber parses numbers with comma separators (e.g., "1,000", "1,234,567")
 // but NOT decimal numbers like "65000.25"
 func parseCommaSeparatedNumber(s string) (int64, error) {
@@ -1591,24 +1914,8 @@ func parseTimeDuration(s string) (int64, error) {
 		return 0, fmt.Errorf("empty string")
 	}
 
-	// Time unit suffixes and their conversion to seconds
-	timeUnits := map[string]int64{
-		"s": 1,        // seconds
-		"m": 60,       // minutes
-		"h": 3600,     // hours
-		"d": 86400,    // days
-		"w": 604800,   // weeks
-		"y": 31536000, // years (365 days)
-	}
-
-	// Check for time unit suffixes
-	for suffix, multiplier := range timeUnits {
-		if strings.HasSuffix(s, suffix) {
-			numStr := strings.TrimSuffix(s, suffix)
-			if num, err := strconv.ParseFloat(numStr, 64); err == nil {
-				return int64(num * float64(multiplier)), nil
-			}
-		}
+	if num, ok := DefaultUnits.lookupFamily(s, "time"); ok {
+		return ratToInt64(num), nil
 	}
 
 	return 0, fmt.Errorf("not a valid time duration: %s", s)
@@ -1622,57 +1929,8 @@ func parseByteSize(s string) (int64, error) {
 		return 0, fmt.Errorf("empty string")
 	}
 
-	// Binary units (base 1024)
-	binaryUnits := map[string]int64{
-		"B":   1,
-		"KiB": 1024,
-		"MiB": 1024 * 1024,
-		"GiB": 1024 * 1024 * 1024,
-		"TiB": 1024 * 1024 * 1024 * 1024,
-		"PiB": 1024 * 1024 * 1024 * 1024 * 1024,
-		"EiB": 1024 * 1024 * 1024 * 1024 * 1024 * 1024,
-	}
-
-	// Decimal units (base 1000)
-	decimalUnits := map[string]int64{
-		"KB": 1000,
-		"MB": 1000 * 1000,
-		"GB": 1000 * 1000 * 1000,
-		"TB": 1000 * 1000 * 1000 * 1000,
-		"PB": 1000 * 1000 * 1000 * 1000 * 1000,
-		"EB": 1000 * 1000 * 1000 * 1000 * 1000 * 1000,
-	}
-
-	// Check for binary units first (they have 'i' in them)
-	for suffix, multiplier := range binaryUnits {
-		if strings.HasSuffix(s, suffix) {
-			numStr := strings.TrimSuffix(s, suffix)
-			if num, err := strconv.ParseFloat(numStr, 64); err == nil {
-				return int64(num * float64(multiplier)), nil
-			}
-		}
-	}
-
-	// Check for decimal units
-	for suffix, multiplier := range decimalUnits {
-		if strings.HasSuffix(s, suffix) {
-			numStr := strings.TrimSuffix(s, suffix)
-			if num, err := strconv.ParseFloat(numStr, 64); err == nil {
-				return int64(num * float64(multiplier)), nil
-			}
-		}
-	}
-
-	// Check for standalone 'B' (bytes)
-	if strings.HasSuffix(s, "B") && !strings.HasSuffix(s, "KB") && !strings.HasSuffix(s, "MB") &&
-		!strings.HasSuffix(s, "GB") && !strings.HasSuffix(s, "TB") && !strings.HasSuffix(s, "PB") &&
-		!strings.HasSuffix(s, "EB") && !strings.HasSuffix(s, "KiB") && !strings.HasSuffix(s, "MiB") &&
-		!strings.HasSuffix(s, "GiB") && !strings.HasSuffix(s, "TiB") && !strings.HasSuffix(s, "PiB") &&
-		!strings.HasSuffix(s, "EiB") {
-		numStr := strings.TrimSuffix(s, "B")
-		if num, err := strconv.ParseFloat(numStr, 64); err == nil {
-			return int64(num), nil
-		}
+	if num, ok := DefaultUnits.lookupFamily(s, "bytes"); ok {
+		return ratToInt64(num), nil
 	}
 
 	return 0, fmt.Errorf("not a valid byte size: %s", s)
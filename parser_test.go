@@ -369,6 +369,30 @@ func TestSyntaxErrors(t *testing.T) {
 	}
 }
 
+func TestSyntaxErrorColumns(t *testing.T) {
+	people := []Person{{Name: "Alice"}}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"Name 'Alice'", `got STRING ("Alice") (column 6)`},
+		{"Age > 1,000 AND Name 'Alice'", `got STRING ("Alice") (column 22)`},
+		{"Name = 'Alice' Age", "unexpected token after end of query (column 16)"},
+		{"Name = 'Alice')", "unexpected closing ) (column 15)"},
+		{"(Name = 'Alice'", "missing closing ) (column 16)"},
+		{"Name IN ('Alice',)", "after comma in IN() (column 18)"},
+		{"Name = 'Ålice' AND", "invalid expression after AND (column 19)"},
+		{"Age > 1e2000", "number is too large: 1e2000 (column 7)"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.query, people)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q) error = %v, want it to contain %q", tt.query, err, tt.want)
+		}
+	}
+}
+
 func TestMapFields(t *testing.T) {
 	// Map field access should now be working
 
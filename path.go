@@ -0,0 +1,385 @@
+package parser
+
+import (
+	"container/list"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxCachedPaths bounds pathCache. Field paths come from query text, so the cache
+// can't keep every path it sees.
+const maxCachedPaths = 1024
+
+// pathCache holds the segments of recently evaluated field paths, since paths are
+// split for each item a query is evaluated against
+var pathCache = &pathLRU{entries: make(map[string]*list.Element), order: list.New()}
+
+// pathLRU is a cache of parsed field paths that drops the least recently used path
+// when it is full
+type pathLRU struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element // values are *pathEntry
+	order   *list.List               // most recently used first
+}
+
+type pathEntry struct {
+	path     string
+	segments []pathSegment
+}
+
+func (c *pathLRU) get(path string) ([]pathSegment, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[path]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*pathEntry).segments, true
+}
+
+// peek is get without marking the path as used
+func (c *pathLRU) peek(path string) ([]pathSegment, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[path]; ok {
+		return e.Value.(*pathEntry).segments, true
+	}
+	return nil, false
+}
+
+func (c *pathLRU) add(path string, segments []pathSegment) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[path]; ok {
+		return
+	}
+	c.entries[path] = c.order.PushFront(&pathEntry{path, segments})
+	if c.order.Len() > maxCachedPaths {
+		oldest := c.order.Remove(c.order.Back()).(*pathEntry)
+		delete(c.entries, oldest.path)
+	}
+}
+
+func (c *pathLRU) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// pathSegment is one step of a field path: a field or map key name, or an index or
+// range into a slice or array, as in Addresses[0] and Scores[1:3]
+type pathSegment struct {
+	name  string
+	index *pathIndex
+
+	wildcard  bool // *: every value of a map or field of a struct
+	elements  bool // [*]: every element of a slice, or as * on a map or struct
+	keys      bool // the keys of a map, for KEYS(...)
+	recursive bool // ..name: matched at any depth below the current value
+	typeName  bool // the name of the value's type, for TYPE(...)
+}
+
+// pathIndex is an [i] or [from:to] segment. Negative positions count from the end.
+type pathIndex struct {
+	from, to       int
+	span           bool // [from:to] rather than [from]
+	hasFrom, hasTo bool
+}
+
+// elementsName stands in splitPath's result for a [*] segment, which reads no name
+// on a slice and every name, as *, on a map or struct
+const elementsName = "[*]"
+
+// splitPath returns the names in a field path, without its quoting, index segments
+// and TYPE() function. Wildcards are returned as "*", as are KEYS() and VALUES(),
+// which read every entry of a map, a [*] segment as elementsName, and a recursive
+// segment such as ..Name as "**" (any number of names) followed by the name.
+func splitPath(path string) []string {
+	segments, ok := pathCache.peek(path)
+	if !ok {
+		// Paths are checked before a query is accepted, so only evaluation, of
+		// a query that passed, adds them to the cache
+		segments = parsePathSegments(path)
+	}
+	names := make([]string, 0, len(segments))
+	for _, seg := range segments {
+		if seg.recursive {
+			names = append(names, "**")
+		}
+		switch {
+		case seg.elements:
+			names = append(names, elementsName)
+		case seg.index == nil && !seg.typeName:
+			names = append(names, seg.name)
+		}
+	}
+	return names
+}
+
+// pathSegments splits a field path into its segments. Segments are separated by
+// dots, and a segment can be quoted to contain dots, spaces or keyword names: with
+// backticks (Labels.`app.kubernetes.io/name`, with a backtick written twice) or
+// as a string in brackets (Labels['team name']). Unquoted brackets index slices and
+// arrays: Addresses[0], History[-1], Scores[1:3]. An unquoted * segment matches
+// every map value or struct field, KEYS(...) and VALUES(...) around the last part
+// of a path read a map's keys or values, and TYPE(...) the name of its type; TYPE()
+// is the type of the item itself.
+//
+// Two dots make the next segment recursive, so Spec..Image finds Image at any depth
+// below Spec. JSONPath spellings are accepted too: a leading $ or $. is dropped and
+// [*] selects every element of a slice, so $.spec.containers[*].image reads the
+// image of each container. On a map or struct [*] is the same as *.
+//
+// The result is cached and shared, and must not be modified.
+func pathSegments(path string) []pathSegment {
+	if segments, ok := pathCache.get(path); ok {
+		return segments
+	}
+	segments := parsePathSegments(path)
+	pathCache.add(path, segments)
+	return segments
+}
+
+// parsePathSegments is pathSegments without the cache
+func parsePathSegments(path string) []pathSegment {
+	if fn, inner, ok := cutPathFunction(path); ok {
+		var segments []pathSegment
+		if inner != "" {
+			segments = parsePathSegments(inner)
+		}
+		if fn == "TYPE" {
+			segments = append(segments, pathSegment{name: "TYPE()", typeName: true})
+		} else {
+			segments = append(segments, pathSegment{name: "*", wildcard: fn == "VALUES", keys: fn == "KEYS"})
+		}
+		return segments
+	}
+
+	var segments []pathSegment
+	var cur strings.Builder
+	quoted := false    // cur holds a quoted name, so * is not a wildcard
+	recursive := false // the next segment follows ..
+	add := func(seg pathSegment) {
+		seg.recursive = recursive && seg.index == nil
+		segments = append(segments, seg)
+		recursive = false
+	}
+	push := func() {
+		name := cur.String()
+		add(pathSegment{name: name, wildcard: name == "*" && !quoted})
+		cur.Reset()
+		quoted = false
+	}
+	bracket := false // the previous segment was bracketed, so a dot starts no new one
+
+	i := 0
+	if strings.HasPrefix(path, "$") {
+		i = 1
+		if strings.HasPrefix(path, "$.") && !strings.HasPrefix(path, "$..") {
+			i = 2
+		}
+	}
+	for i < len(path) {
+		switch c := path[i]; {
+		case c == '.' && i+1 < len(path) && path[i+1] == '.':
+			if !bracket && (cur.Len() > 0 || quoted) {
+				push()
+			}
+			recursive = true
+			bracket = false
+			i += 2
+		case c == '.':
+			if !bracket {
+				push()
+			}
+			bracket = false
+			i++
+		case c == '`':
+			value, end, err := scanBacktick(path, i)
+			if err != nil {
+				cur.WriteString(path[i:])
+				i = len(path)
+				continue
+			}
+			cur.WriteString(value)
+			quoted = true
+			i = end
+		case c == '[' && i+1 < len(path) && (path[i+1] == '\'' || path[i+1] == '"'):
+			value, end, err := scanString(path, i+1)
+			if err != nil || end >= len(path) || path[end] != ']' {
+				cur.WriteString(path[i:])
+				i = len(path)
+				continue
+			}
+			if cur.Len() > 0 {
+				push()
+			}
+			add(pathSegment{name: value})
+			bracket = true
+			i = end + 1
+		case strings.HasPrefix(path[i:], "[*]"):
+			if cur.Len() > 0 {
+				push()
+			}
+			add(pathSegment{name: "*", wildcard: true, elements: true})
+			bracket = true
+			i += 3
+		case c == '[' && (cur.Len() > 0 || bracket):
+			end := strings.IndexByte(path[i:], ']')
+			index, ok := parsePathIndex(path[i+1 : i+max(end, 1)])
+			if end < 0 || !ok {
+				cur.WriteByte(c)
+				i++
+				continue
+			}
+			if cur.Len() > 0 {
+				push()
+			}
+			add(pathSegment{index: index})
+			bracket = true
+			i += end + 1
+		default:
+			bracket = false
+			cur.WriteByte(c)
+			i++
+		}
+	}
+	if !bracket {
+		push()
+	}
+
+	return segments
+}
+
+// parsePathIndex parses the inside of an index segment: i, from:to, from: or :to
+func parsePathIndex(s string) (*pathIndex, bool) {
+	from, to, span := strings.Cut(s, ":")
+	index := &pathIndex{span: span}
+	var err error
+	if from != "" {
+		if index.from, err = strconv.Atoi(from); err != nil {
+			return nil, false
+		}
+		index.hasFrom = true
+	}
+	if to != "" {
+		if index.to, err = strconv.Atoi(to); err != nil {
+			return nil, false
+		}
+		index.hasTo = true
+	}
+	return index, span || index.hasFrom
+}
+
+// apply returns the element of a slice or array v at the index, or the part of it
+// within the range. Ranges are clipped to v; an index out of range, or a range with
+// nothing in it, has no element.
+func (ix *pathIndex) apply(v reflect.Value) (reflect.Value, bool) {
+	n := v.Len()
+	position := func(i int) int {
+		if i < 0 {
+			i += n
+		}
+		return i
+	}
+	if !ix.span {
+		i := position(ix.from)
+		if i < 0 || i >= n {
+			return reflect.Value{}, false
+		}
+		return v.Index(i), true
+	}
+
+	from, to := 0, n
+	if ix.hasFrom {
+		from = min(max(position(ix.from), 0), n)
+	}
+	if ix.hasTo {
+		to = min(max(position(ix.to), 0), n)
+	}
+	if to <= from {
+		return reflect.Value{}, false
+	}
+	if v.Kind() == reflect.Array && !v.CanAddr() {
+		// Arrays read through an interface or map can't be sliced in place
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		v = c
+	}
+	return v.Slice(from, to), true
+}
+
+func (ix *pathIndex) String() string {
+	var b strings.Builder
+	b.WriteByte('[')
+	if ix.hasFrom {
+		b.WriteString(strconv.Itoa(ix.from))
+	}
+	if ix.span {
+		b.WriteByte(':')
+		if ix.hasTo {
+			b.WriteString(strconv.Itoa(ix.to))
+		}
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// missingValue stands in for a value a path may reach on some items but not others:
+// the element at an index out of range, what a recursive segment finds when nothing
+// matches, or a nil value in a map[string]any such as a JSON null. It is a nil
+// pointer, so it compares as NULL, and it stays NULL along the rest of the path.
+var missingValue = reflect.ValueOf((*missingElement)(nil))
+
+type missingElement struct{}
+
+func isMissingValue(v reflect.Value) bool {
+	return v.IsValid() && v.Type() == missingValue.Type()
+}
+
+// canonicalPath returns path with its quoting removed, e.g. Labels['team'] becomes
+// Labels.team, which is the form allow and deny patterns are matched against
+func canonicalPath(path string) string {
+	return strings.Join(splitPath(path), ".")
+}
+
+// scanBacktick decodes the backtick-quoted segment starting at s[start]. A backtick
+// inside it is written twice. end is the index just past the closing backtick.
+func scanBacktick(s string, start int) (value string, end int, err error) {
+	var b strings.Builder
+	for i := start + 1; i < len(s); i++ {
+		if s[i] == '`' {
+			if i+1 < len(s) && s[i+1] == '`' {
+				b.WriteByte('`')
+				i++
+				continue
+			}
+			return b.String(), i + 1, nil
+		}
+		b.WriteByte(s[i])
+	}
+	return "", len(s), fmt.Errorf("unclosed quoted identifier: %s", s[start:])
+}
+
+// scanBracket returns the end of the bracketed segment starting at s[start], which
+// holds a quoted string or, unquoted, anything up to the closing bracket
+func scanBracket(s string, start int) (end int, err error) {
+	i := start + 1
+	if i < len(s) && (s[i] == '\'' || s[i] == '"') {
+		_, end, err := scanString(s, i)
+		if err != nil {
+			return end, err
+		}
+		i = end
+	}
+	for ; i < len(s); i++ {
+		if s[i] == ']' {
+			return i + 1, nil
+		}
+	}
+	return len(s), fmt.Errorf("unclosed bracket in field path: %s", s[start:])
+}
@@ -0,0 +1,220 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSplitPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"Name", []string{"Name"}},
+		{"Department.Name", []string{"Department", "Name"}},
+		{"Labels.`app.kubernetes.io/name`", []string{"Labels", "app.kubernetes.io/name"}},
+		{"`Not`", []string{"Not"}},
+		{"Labels.`a``b`", []string{"Labels", "a`b"}},
+		{"Labels['team name']", []string{"Labels", "team name"}},
+		{`Labels["team.name"].x`, []string{"Labels", "team.name", "x"}},
+		{"Labels['a']['b']", []string{"Labels", "a", "b"}},
+		{"Labels['it''s']", []string{"Labels", "it's"}},
+		{"Addresses[0].City", []string{"Addresses", "City"}},
+		{"Scores[1:3]", []string{"Scores"}},
+		{"Labels['0'][-1]", []string{"Labels", "0"}},
+	}
+	for _, tt := range tests {
+		if got := splitPath(tt.path); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestQuotedIdentifiers(t *testing.T) {
+	type Keywords struct {
+		Not  string
+		Any  int
+		Name string
+	}
+	type Resource struct {
+		Name   string
+		Labels map[string]string
+		Flags  Keywords
+	}
+
+	resources := []Resource{
+		{Name: "api", Labels: map[string]string{"app.kubernetes.io/name": "api", "team name": "core", "app-name": "backend", "10m": "x"}, Flags: Keywords{Not: "yes", Any: 1}},
+		{Name: "web", Labels: map[string]string{"app.kubernetes.io/name": "web", "team name": "edge", "app-name": "frontend", "10m": "y"}, Flags: Keywords{Not: "no", Any: 2}},
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"Labels.`app.kubernetes.io/name` = 'web'", "web"},
+		{"Labels['team name'] = 'core'", "api"},
+		{`Labels["team name"] = 'core'`, "api"},
+		{"Labels.`app-name` = 'frontend'", "web"},
+		{"Labels['app-name'] != 'backend'", "web"},
+		{"Flags.`Not` = 'yes'", "api"},
+		{"Flags.`Any` > 1", "web"},
+		{"`Name` = 'api'", "api"},
+		{"NOT `Name` = 'api'", "web"},
+		{"Labels.`10m` = 'x'", "api"},
+		{"ANY(Labels.`app.kubernetes.io/name`) = ANY('web')", "web"},
+		{"LOWER(Labels['team name']) = 'core'", "api"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, err := Parse(tt.query, resources)
+			if err != nil {
+				t.Fatalf("Parse returned error: %v", err)
+			}
+			if len(results) != 1 || results[0].Name != tt.want {
+				t.Errorf("got %v, want only %s", results, tt.want)
+			}
+		})
+	}
+
+	for _, query := range []string{"Labels.`unclosed = 'x'", "Labels['unclosed = 'x'"} {
+		if _, err := Parse(query, resources); err == nil {
+			t.Errorf("Parse(%q) expected an error", query)
+		}
+	}
+
+	// Quoting doesn't get around allow and deny lists
+	for _, query := range []string{"Labels.`team name` = 'x'", "Labels['team name'] = 'x'", "`Labels`.`team name` = 'x'"} {
+		if _, err := Parse(query, resources, WithDeniedFields("Labels.team name")); !errors.Is(err, ErrFieldNotAllowed) {
+			t.Errorf("Parse(%q) expected ErrFieldNotAllowed, got %v", query, err)
+		}
+	}
+}
+
+func TestPathIndexSegments(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"Addresses[0].City", []string{"Addresses", "[0]", "City"}},
+		{"History[-1].Status", []string{"History", "[-1]", "Status"}},
+		{"Scores[1:3]", []string{"Scores", "[1:3]"}},
+		{"Scores[:2]", []string{"Scores", "[:2]"}},
+		{"Scores[-2:]", []string{"Scores", "[-2:]"}},
+		{"Matrix[0][1]", []string{"Matrix", "[0]", "[1]"}},
+		{"Labels['0'][0]", []string{"Labels", "0", "[0]"}},
+		{"Labels[x]", []string{"Labels[x]"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, seg := range pathSegments(tt.path) {
+			if seg.index != nil {
+				got = append(got, seg.index.String())
+			} else {
+				got = append(got, seg.name)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("pathSegments(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestIndexedPaths(t *testing.T) {
+	type Address struct {
+		City string
+	}
+	type Event struct {
+		Status string
+	}
+	type Record struct {
+		Name      string
+		Addresses []Address
+		History   []*Event
+		Scores    []int
+		Grid      [2][2]int
+		Extra     map[string]any
+	}
+
+	records := []Record{
+		{
+			Name:      "a",
+			Addresses: []Address{{City: "Oslo"}, {City: "Bergen"}},
+			History:   []*Event{{Status: "new"}, {Status: "done"}},
+			Scores:    []int{1, 2, 3, 4},
+			Grid:      [2][2]int{{1, 2}, {3, 4}},
+			Extra:     map[string]any{"tags": []any{"x", "y"}, "pair": [2]string{"p", "q"}},
+		},
+		{
+			Name:      "b",
+			Addresses: []Address{{City: "Bergen"}},
+			History:   []*Event{{Status: "new"}},
+			Scores:    []int{9},
+			Grid:      [2][2]int{{5, 6}, {7, 8}},
+			Extra:     map[string]any{"tags": []any{"y"}, "pair": [2]string{"q", "p"}},
+		},
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"Addresses[0].City = 'Bergen'", []string{"b"}},
+		{"Addresses[1].City = 'Bergen'", []string{"a"}},
+		{"Addresses[-1].City = 'Bergen'", []string{"a", "b"}},
+		{"History[-1].Status = 'done'", []string{"a"}},
+		{"History[0].Status = 'new'", []string{"a", "b"}},
+		{"Addresses[1].City IS NULL", []string{"b"}},
+		{"Addresses[5].City != 'Oslo'", nil},
+		{"Scores[5] IS NULL", []string{"a", "b"}},
+		{"Scores[1:3] = 3", []string{"a"}},
+		{"Scores[1:3] = 4", nil},
+		{"Scores[1:3] IS NULL", []string{"b"}},
+		{"Scores[:1] = 9", []string{"b"}},
+		{"Scores[-2:] = 3", []string{"a"}},
+		{"Scores[1:3] = [2, 3]", []string{"a"}},
+		{"Grid[1][0] = 7", []string{"b"}},
+		{"Grid[1] = 7", []string{"b"}},
+		{"Grid[0] CONTAINS ALL (5, 6)", []string{"b"}},
+		{"Extra.tags[0] = 'y'", []string{"b"}},
+		{"Extra.pair[0] = 'p'", []string{"a"}},
+		{"Extra.pair[0:1] = 'q'", []string{"b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, err := Parse(tt.query, records)
+			if err != nil {
+				t.Fatalf("Parse returned error: %v", err)
+			}
+			checkNames(t, tt.query, results, tt.want)
+		})
+	}
+
+	// Indexes don't get around allow and deny lists
+	if _, err := Parse("Addresses[0].City = 'Oslo'", records, WithDeniedFields("Addresses.City")); !errors.Is(err, ErrFieldNotAllowed) {
+		t.Errorf("expected ErrFieldNotAllowed, got %v", err)
+	}
+}
+
+func TestPathCacheIsBounded(t *testing.T) {
+	records := []map[string]any{{"name": "a"}}
+	for i := 0; i < 2*maxCachedPaths; i++ {
+		query := fmt.Sprintf("labels.k%d = 'x'", i)
+		if _, err := Parse(query, records); err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", query, err)
+		}
+	}
+	if n := pathCache.len(); n > maxCachedPaths {
+		t.Errorf("path cache holds %d paths, want at most %d", n, maxCachedPaths)
+	}
+
+	// A rejected path is never evaluated, so it isn't cached
+	_, err := Parse("labels.`secret path` = 'x'", records, WithDeniedFields("labels.*"))
+	if !errors.Is(err, ErrFieldNotAllowed) {
+		t.Fatalf("expected ErrFieldNotAllowed, got %v", err)
+	}
+	if _, ok := pathCache.peek("labels.`secret path`"); ok {
+		t.Errorf("a rejected path was cached")
+	}
+}
@@ -0,0 +1,50 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnknown is returned, under every MissingFieldPolicy, by Evaluate for a
+// predicate that is neither true nor false, such as a comparison with NULL or, under
+// MissingFieldLenient, a missing field. NOT of it is unknown, AND and OR combine it
+// by three-valued logic, and an item whose query is unknown doesn't match.
+var ErrUnknown = errors.New("predicate is unknown")
+
+// errSkipItem is wrapped by failures under MissingFieldSkip, which leave out the
+// item however the rest of the query would have evaluated
+var errSkipItem = errors.New("item skipped")
+
+// fail applies the policy to err, a missing field or a value that can't be compared
+func (o *options) fail(err error) error {
+	switch o.orDefault().missingFields {
+	case MissingFieldLenient:
+		return ErrUnknown
+	case MissingFieldSkip:
+		return fmt.Errorf("%w: %w", errSkipItem, err)
+	}
+	return err
+}
+
+// settle returns the result of a predicate over the values of a field: whether
+// one matched, whether one was NULL, and the last error from a value that couldn't
+// be compared. A NULL value, or under MissingFieldLenient a failed one, only leaves
+// the result unknown if nothing else matched.
+func (o *options) settle(matched, null bool, err error) (bool, error) {
+	o = o.orDefault()
+	if err != nil && o.missingFields != MissingFieldLenient {
+		return false, o.fail(err)
+	}
+	if matched {
+		return true, nil
+	}
+	if err != nil || null {
+		return false, ErrUnknown
+	}
+	return false, nil
+}
+
+// lenient reports whether failures make a predicate unknown rather than an error
+func (o *options) lenient() bool {
+	return o.orDefault().missingFields == MissingFieldLenient
+}
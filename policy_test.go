@@ -0,0 +1,117 @@
+package parser
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMissingFieldPolicies(t *testing.T) {
+	records := []map[string]any{
+		{"name": "a", "age": 30, "items": []any{map[string]any{"q": 1}}, "tags": []any{"x", 1}},
+		{"name": "b", "age": "old", "items": []any{map[string]any{"q": "x"}, map[string]any{"q": 2}}, "tags": []any{"y"}},
+		{"name": "c", "items": []any{map[string]any{"q": 3}}, "tags": []any{"z", 3}},
+	}
+
+	const fails = "error"
+	tests := []struct {
+		query                 string
+		strict, lenient, skip string
+	}{
+		// a's age can't be compared with a string, and c has no age, which is NULL
+		// under every policy
+		{"age = 'old'", fails, "b", "b"},
+		{"age = 'old' OR name = 'a'", fails, "a,b", "b"},
+		{"name = 'a' OR age = 'old'", "a,b", "a,b", "a,b"},
+		{"NOT (age = 'old')", fails, "", ""},
+		{"NOT (age = 'old' AND name = 'c')", fails, "a,b", "b"},
+		{"name = 'b' AND age = 'old'", "b", "b", "b"},
+		{"age IS NULL", "c", "c", "c"},
+		{"age IS NOT NULL", "a,b", "a,b", "a,b"},
+		{"ANY(age) = ANY(30, 'old')", "a,b", "a,b", "a,b"},
+		{"missing = 1 OR name = 'a'", "a", "a", "a"},
+
+		// q can't be compared with 'x' in a's and c's items
+		{"items ANY (q = 'x')", fails, "b", "b"},
+		{"items ALL (q != 'x')", fails, "", ""},
+		{"items.q CONTAINS ANY ('x', 'y')", fails, "b", "b"},
+		{"items.q CONTAINS ANY ('x', 3)", "b,c", "b,c", "b,c"},
+
+		// Mixed arrays: a number among strings is passed over when another
+		// element can be compared
+		{"tags = 'x'", "a", "a", "a"},
+		{"tags != 'x'", "b,c", "b,c", "b,c"},
+		{"NOT (tags = 'x')", "b,c", "b,c", "b,c"},
+		{"tags CONTAINS 'x'", "a", "a", "a"},
+		{"ANY(tags) = 'x'", "a", "a", "a"},
+		{"tags IN ('x', 'y')", "a,b", "a,b", "a,b"},
+		{"tags CONTAINS ALL ('x')", "a", "a", "a"},
+		{"tags CONTAINS ONLY ('x')", "", "", ""},
+	}
+	for _, tt := range tests {
+		policies := []struct {
+			name   string
+			policy MissingFieldPolicy
+			want   string // names separated by commas
+		}{
+			{"strict", MissingFieldStrict, tt.strict},
+			{"lenient", MissingFieldLenient, tt.lenient},
+			{"skip", MissingFieldSkip, tt.skip},
+		}
+		for _, p := range policies {
+			t.Run(p.name+"/"+tt.query, func(t *testing.T) {
+				results, err := Parse(tt.query, records, WithMissingFields(p.policy))
+				if p.want == fails {
+					if err == nil {
+						t.Errorf("Parse(%q) = %v, want an error", tt.query, results)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("Parse(%q) returned error: %v", tt.query, err)
+				}
+				checkNames(t, tt.query, results, strings.FieldsFunc(p.want, func(r rune) bool { return r == ',' }))
+			})
+		}
+	}
+}
+
+func TestMissingFieldPolicyErrors(t *testing.T) {
+	// OR reports errors that aren't about the item, such as an ambiguous map key,
+	// under every policy
+	records := []map[string]any{{"Env": "a", "ENV": "b", "name": "x"}}
+	for _, policy := range []MissingFieldPolicy{MissingFieldStrict, MissingFieldLenient, MissingFieldSkip} {
+		_, err := Parse("name = 'x' OR env = 'a'", records, WithMissingFields(policy), WithMapKeyMatching(MapKeyFoldStrict))
+		if err != nil {
+			t.Errorf("policy %d: a true first operand should decide the OR, got %v", policy, err)
+		}
+		_, err = Parse("name = 'y' OR env = 'a'", records, WithMissingFields(policy), WithMapKeyMatching(MapKeyFoldStrict))
+		if err == nil || !strings.Contains(err.Error(), "ambiguous map key") {
+			t.Errorf("policy %d: expected an ambiguous map key error, got %v", policy, err)
+		}
+	}
+
+	// Strict errors name the field and reach the caller through NOT
+	_, err := Parse("NOT (age = 'old')", []map[string]any{{"name": "a", "age": 30}})
+	if err == nil || !strings.Contains(err.Error(), "age") {
+		t.Errorf("expected an error about age, got %v", err)
+	}
+}
+
+func TestEvaluateUnknown(t *testing.T) {
+	// Expressions evaluated directly report an unknown predicate as ErrUnknown
+	p := NewParser(NewEnhancedLexer("manager.name = 'x'"))
+	expr, err := p.ParseQuery()
+	if err != nil {
+		t.Fatalf("ParseQuery returned error: %v", err)
+	}
+	match, err := expr.Evaluate(reflect.ValueOf(map[string]any{"manager": nil}))
+	if match || !errors.Is(err, ErrUnknown) {
+		t.Errorf("Evaluate = %v, %v, want ErrUnknown", match, err)
+	}
+	match, err = expr.Evaluate(reflect.ValueOf(map[string]any{"manager": map[string]any{"name": "x"}}))
+	if !match || err != nil {
+		t.Errorf("Evaluate = %v, %v, want true", match, err)
+	}
+}
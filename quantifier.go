@@ -0,0 +1,116 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// QuantifierExpression applies a predicate to each element of a slice field:
+// Addresses ANY (City = 'Oslo' AND Zip = '0150') matches if one address satisfies
+// the whole predicate, and Orders ALL (Status = 'paid') if every order does. Field
+// paths in the predicate are relative to the element, and quantifiers nest.
+type QuantifierExpression struct {
+	Field      string
+	Quantifier TokenType // ANY or ALL
+	Predicate  Expression
+
+	opts *options
+}
+
+// Evaluate for QuantifierExpression. ANY over no elements is false and ALL over no
+// elements is true; over a nil slice, which is NULL, both are unknown.
+func (qe *QuantifierExpression) Evaluate(item reflect.Value) (bool, error) {
+	elems, err := getFieldValues(item, qe.Field, qe.opts)
+	if err != nil {
+		if !errors.Is(err, errFieldNotFound) {
+			return false, err
+		}
+		// A missing slice is NULL with MissingFieldLenient, which is unknown
+		return false, qe.opts.fail(fmt.Errorf("field '%s' not found", qe.Field))
+	}
+	if isNullSlice(elems) {
+		return false, ErrUnknown // ANY and ALL over a NULL slice
+	}
+
+	// An element the predicate is unknown for leaves the result unknown, unless
+	// another element decides it
+	unknown := false
+	for _, elem := range elems {
+		for elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Interface {
+			if elem.IsNil() {
+				break
+			}
+			elem = elem.Elem()
+		}
+		if (elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Interface) && elem.IsNil() {
+			continue
+		}
+
+		match, err := qe.Predicate.Evaluate(elem)
+		if errors.Is(err, ErrUnknown) {
+			unknown = true
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("in %s %s: %w", qe.Field, qe.Quantifier, err)
+		}
+		if qe.Quantifier == ALL && !match {
+			return false, nil
+		}
+		if qe.Quantifier == ANY && match {
+			return true, nil
+		}
+	}
+	if unknown {
+		return false, ErrUnknown
+	}
+	return qe.Quantifier == ALL, nil
+}
+
+// parseQuantifier parses "ANY (predicate)" or "ALL (predicate)" after field
+func (p *Parser) parseQuantifier(field string) Expression {
+	quantifier := p.currentToken.Type
+	p.nextToken() // consume ANY/ALL
+
+	if !p.currentTokenIs(LPAREN) {
+		p.errorf("expected '(' after %s %s", field, quantifier)
+		return nil
+	}
+	p.nextToken() // consume '('
+	if p.currentTokenIs(RPAREN) {
+		p.errorf("expected predicate inside %s %s()", field, quantifier)
+		return nil
+	}
+
+	outer, outerOpts := p.scope, p.opts
+	p.scope, p.opts = joinPath(outer, field), p.opts.scoped(field)
+	predicate := p.parseOrExpression()
+	p.scope, p.opts = outer, outerOpts
+	if predicate == nil {
+		return nil
+	}
+
+	if !p.currentTokenIs(RPAREN) {
+		p.errorf("expected ')' after predicate in %s %s()", field, quantifier)
+		return nil
+	}
+	p.nextToken() // consume ')'
+
+	return &QuantifierExpression{Field: field, Quantifier: quantifier, Predicate: predicate, opts: p.opts}
+}
+
+// joinPath appends a relative field path to the path of the enclosing quantifiers.
+// An empty field is the element itself, at the path of the scope.
+func joinPath(scope, field string) string {
+	if scope == "" || field == "" {
+		return scope + field
+	}
+	field = strings.TrimPrefix(field, "$")
+	if strings.HasPrefix(field, "..") {
+		// ..Name stays recursive
+		return scope + field
+	}
+	return scope + "." + strings.TrimPrefix(field, ".")
+}
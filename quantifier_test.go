@@ -0,0 +1,178 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type qAddress struct {
+	City string
+	Zip  string
+}
+
+type qLine struct {
+	SKU string
+	Qty int
+}
+
+type qOrder struct {
+	Status string
+	Lines  []qLine
+}
+
+type qCustomer struct {
+	Name      string
+	Addresses []qAddress
+	Orders    []*qOrder
+}
+
+func TestQuantifiers(t *testing.T) {
+	customers := []qCustomer{
+		{
+			Name:      "split",
+			Addresses: []qAddress{{City: "Oslo", Zip: "0151"}, {City: "Bergen", Zip: "0150"}},
+			Orders:    []*qOrder{{Status: "paid", Lines: []qLine{{SKU: "A", Qty: 1}}}, nil},
+		},
+		{
+			Name:      "oslo",
+			Addresses: []qAddress{{City: "Oslo", Zip: "0150"}},
+			Orders:    []*qOrder{{Status: "paid", Lines: []qLine{{SKU: "A", Qty: 5}}}, {Status: "open", Lines: []qLine{{SKU: "B", Qty: 1}}}},
+		},
+		{
+			Name:      "empty",
+			Addresses: []qAddress{},
+			Orders:    []*qOrder{},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"ANY same element", "Addresses ANY (City = 'Oslo' AND Zip = '0150')", []string{"oslo"}},
+		{"ANY single predicate", "Addresses ANY (City = 'Bergen')", []string{"split"}},
+		{"ANY over empty is false", "Addresses ANY (City != 'x')", []string{"split", "oslo"}},
+		{"ALL", "Orders ALL (Status = 'paid')", []string{"split", "empty"}},
+		{"ALL skips nil elements", "Orders ALL (Status = 'paid') AND Name = 'split'", []string{"split"}},
+		{"NOT ALL", "NOT Orders ALL (Status = 'paid')", []string{"oslo"}},
+		{"OR inside", "Addresses ANY (City = 'Bergen' OR Zip = '0150')", []string{"split", "oslo"}},
+		{"Nested", "Orders ANY (Status = 'paid' AND Lines ANY (SKU = 'A' AND Qty > 2))", []string{"oslo"}},
+		{"Nested ALL in ANY", "Orders ANY (Lines ALL (Qty = 1))", []string{"split", "oslo"}},
+		{"Combined with outer fields", "Name != 'split' AND Addresses ANY (Zip = '0150')", []string{"oslo"}},
+		{"IN inside", "Addresses ANY (City IN ('Bergen', 'Trondheim'))", []string{"split"}},
+		{"Thousands inside", "Orders ANY (Lines ANY (Qty < 1,000 AND Qty > 2))", []string{"oslo"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := Parse(tt.query, customers)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.query, err)
+			}
+			checkNames(t, tt.query, results, tt.want)
+		})
+	}
+}
+
+func TestQuantifierErrors(t *testing.T) {
+	customers := []qCustomer{{Name: "oslo", Addresses: []qAddress{{City: "Oslo", Zip: "0150"}}}}
+
+	tests := []struct {
+		name  string
+		query string
+		opts  []Option
+		want  string
+	}{
+		{"Missing predicate", "Addresses ANY ()", nil, "expected predicate"},
+		{"Missing paren", "Addresses ALL City = 'Oslo'", nil, "expected '('"},
+		{"Unclosed", "Addresses ANY (City = 'Oslo'", nil, "expected ')'"},
+		{"Unknown inner field", "Addresses ANY (Country = 'NO')", nil, "not found"},
+		{"Denied inner field", "Addresses ANY (Zip = '0150')", []Option{WithDeniedFields("Addresses.Zip")}, "not allowed"},
+		{"Allowed prefix", "Addresses ANY (Zip = '0150')", []Option{WithAllowedFields("Name", "Addresses.City")}, "not allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.query, customers, tt.opts...)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Parse(%q) error = %v, want it to contain %q", tt.query, err, tt.want)
+			}
+		})
+	}
+
+	_, err := Parse("Addresses ANY (Zip = '0150')", customers, WithDeniedFields("Addresses.*"))
+	if !errors.Is(err, ErrFieldNotAllowed) {
+		t.Errorf("expected ErrFieldNotAllowed, got %v", err)
+	}
+}
+
+func TestQuantifierFieldAccess(t *testing.T) {
+	customers := []qCustomer{{Name: "oslo", Addresses: []qAddress{{City: "Oslo", Zip: "0150"}}}}
+	denied := WithDeniedFields("Addresses")
+	allowed := WithAllowedFields("Name")
+
+	// The quantified field is read even when the predicate reads nothing else
+	for _, query := range []string{
+		"Addresses ANY (NOT ())",
+		"Addresses ALL (IS qAddress)",
+		"Addresses ANY (TYPE() = 'qAddress')",
+		"Name = 'oslo' OR Addresses ANY (NOT ())",
+	} {
+		for _, opt := range []Option{denied, allowed} {
+			if _, err := Parse(query, customers, opt); !errors.Is(err, ErrFieldNotAllowed) {
+				t.Errorf("Parse(%q) expected ErrFieldNotAllowed, got %v", query, err)
+			}
+		}
+	}
+
+	// An unknown field outside the allowlist gets the same error as a known one
+	if _, err := Parse("Nope ANY (NOT ())", customers, allowed); !errors.Is(err, ErrFieldNotAllowed) {
+		t.Errorf("expected ErrFieldNotAllowed for an unknown field, got %v", err)
+	}
+
+	// Allowing the field and the paths inside is enough
+	results, err := Parse("Addresses ANY (City = 'Oslo')", customers, WithAllowedFields("Addresses", "Addresses.City"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNames(t, "Addresses ANY (City = 'Oslo')", results, []string{"oslo"})
+
+	// Checked again against the type, under the Go names tags alias
+	type tagged struct {
+		Name    string
+		Secrets []string `parser:"vault"`
+	}
+	items := []tagged{{Name: "a", Secrets: []string{"x"}}}
+	if _, err := Parse("vault ANY (IS string)", items, WithDeniedFields("Secrets")); !errors.Is(err, ErrFieldNotAllowed) {
+		t.Errorf("expected ErrFieldNotAllowed for a tag alias, got %v", err)
+	}
+}
+
+func TestQuantifierBind(t *testing.T) {
+	customers := []qCustomer{
+		{Name: "bergen", Addresses: []qAddress{{City: "Oslo", Zip: "0151"}, {City: "Bergen", Zip: "0150"}}},
+		{Name: "oslo", Addresses: []qAddress{{City: "Oslo", Zip: "0150"}}},
+	}
+
+	q, err := Compile("Addresses ANY (City = ? AND Zip IN (:zips))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err = q.Bind("Oslo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err = q.BindNamed(map[string]any{"zips": []string{"0150", "0999"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Filter(q, customers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Name != "oslo" {
+		t.Errorf("got %v, want [oslo]", results)
+	}
+}
@@ -0,0 +1,177 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Query is a compiled query. It can be evaluated against any number of data sets
+// with Filter and is safe for concurrent use.
+type Query struct {
+	expr Expression
+
+	// parts are the compiled queries expr was built from, each with the options it
+	// was compiled with, so fields are checked against the right allow/deny lists
+	parts []queryPart
+}
+
+type queryPart struct {
+	expr Expression
+	opts *options
+}
+
+// Compile parses query once so it can be evaluated repeatedly. An empty query
+// matches everything.
+func Compile(query string, opts ...Option) (q *Query, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			q = nil
+			err = fmt.Errorf("internal error while parsing query: %v", r)
+		}
+	}()
+
+	if query == "" {
+		return &Query{}, nil
+	}
+	o := newOptions(opts)
+	maxLength := o.limits.MaxQueryLength
+	if maxLength > 0 && len(query) > maxLength {
+		return nil, fmt.Errorf("failed to parse query: %w", exceeded(ErrQueryTooLong, maxLength))
+	}
+
+	// Use the enhanced lexer that supports negative numbers, on the query with
+	// humanized values normalized. Units are written out in full, so the length is
+	// checked again.
+	l := newQueryLexer(query, o.unitsOrDefault())
+	if maxLength > 0 && len(l.input) > maxLength {
+		return nil, fmt.Errorf("failed to parse query: %w", exceeded(ErrQueryTooLong, maxLength))
+	}
+	p := NewParser(l, opts...)
+
+	ast, err := p.ParseQuery()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse query: %w", err)
+	}
+	if len(p.Errors()) > 0 {
+		return nil, fmt.Errorf("parsing errors: %s", strings.Join(p.Errors(), "; "))
+	}
+	if ast == nil {
+		return nil, fmt.Errorf("failed to parse query: AST is nil")
+	}
+	return &Query{expr: ast, parts: []queryPart{{expr: ast, opts: p.opts}}}, nil
+}
+
+// Restrict returns a query matching the items that match both user and trusted.
+// The two are combined as separately parsed trees under one AND node, so nothing in
+// the user query (a trailing OR, unbalanced parentheses) can change how the trusted
+// predicate applies. This is meant for row-level security, e.g. a trusted
+// "TenantID = 42" compiled by the application. Each part keeps the options it was
+// compiled with, so trusted may use fields the user query is not allowed to.
+func Restrict(user, trusted *Query) (*Query, error) {
+	if trusted == nil || trusted.expr == nil {
+		return nil, errors.New("trusted predicate is empty")
+	}
+	for _, q := range []*Query{trusted, user} {
+		if q != nil {
+			if p := unboundParameter(q.expr); p != nil {
+				return nil, fmt.Errorf("parameter %s must be bound before Restrict", p)
+			}
+		}
+	}
+	if user == nil || user.expr == nil {
+		return trusted, nil
+	}
+	parts := append(append([]queryPart(nil), trusted.parts...), user.parts...)
+	return &Query{
+		expr:  &ConjunctionExpression{Expressions: []Expression{trusted.expr, user.expr}},
+		parts: parts,
+	}, nil
+}
+
+// Filter returns the items of data that match q. Items are structs, maps such as
+// map[string]any, or pointers or interfaces holding either, so decoded JSON
+// ([]map[string]any, []any) can be queried like structs.
+func Filter[T any](q *Query, data []T) (results []T, err error) {
+	if q != nil && q.expr == nil {
+		return data, nil
+	}
+	results = make([]T, 0, len(data))
+	err = filter(q, data, func(i int) {
+		results = append(results, data[i])
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// filter calls keep with the index of each item of data that matches q
+func filter[T any](q *Query, data []T, keep func(int)) (err error) {
+	// Safety net: no data should be able to crash the caller
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("internal error while evaluating query: %v", r)
+		}
+	}()
+
+	if q == nil {
+		return errors.New("query is nil")
+	}
+	if p := unboundParameter(q.expr); p != nil {
+		return fmt.Errorf("parameter %s is not bound", p)
+	}
+
+	// Report field paths that can never resolve, such as ambiguous promoted fields,
+	// before looking at any data. Records held in interfaces, as in a []Event, are
+	// checked once for each concrete type found.
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if err := q.checkFieldPaths(t); err != nil {
+		return err
+	}
+	checked := map[reflect.Type]bool{}
+
+	for i, item := range data {
+		// Dereference pointers and interfaces down to the record; nil items are skipped
+		val := reflect.ValueOf(item)
+		for (val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface) && !val.IsNil() {
+			val = val.Elem()
+		}
+		if !val.IsValid() || val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+			continue
+		}
+
+		if val.Kind() != reflect.Struct && val.Kind() != reflect.Map {
+			return fmt.Errorf("expected slice of structs or maps, got %s in data", val.Kind())
+		}
+		if t.Kind() == reflect.Interface && !checked[val.Type()] {
+			checked[val.Type()] = true
+			if err := q.checkFieldPaths(val.Type()); err != nil {
+				return err
+			}
+		}
+
+		// An unknown result doesn't match, and MissingFieldSkip leaves out the items
+		// a predicate failed on; any other error is returned immediately
+		match, err := q.expr.Evaluate(val)
+		switch {
+		case errors.Is(err, ErrUnknown), errors.Is(err, errSkipItem):
+		case err != nil:
+			return fmt.Errorf("evaluation error: %w", err)
+		case match:
+			keep(i)
+		}
+	}
+	return nil
+}
+
+// checkFieldPaths checks the field paths of every part of q against the record type t
+func (q *Query) checkFieldPaths(t reflect.Type) error {
+	for _, part := range q.parts {
+		if err := checkFieldPaths(part.expr, t, part.opts); err != nil {
+			return fmt.Errorf("failed to parse query: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package parser
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+type tenantRecord struct {
+	TenantID int
+	Name     string
+	Tags     []int
+}
+
+func TestCompileAndFilter(t *testing.T) {
+	q, err := Compile("Age > 30")
+	if err != nil {
+		t.Fatalf("Compile returned error: %v", err)
+	}
+
+	people := []struct {
+		Name string
+		Age  int
+	}{{"a", 25}, {"b", 35}, {"c", 45}}
+
+	// A compiled query can be reused and shared between goroutines
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results, err := Filter(q, people)
+			if err != nil || len(results) != 2 {
+				t.Errorf("Filter returned %v, %v", results, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	all, err := Compile("")
+	if err != nil {
+		t.Fatalf("Compile of empty query returned error: %v", err)
+	}
+	if results, _ := Filter(all, people); len(results) != 3 {
+		t.Errorf("empty query should match everything, got %d results", len(results))
+	}
+
+	if _, err := Filter[tenantRecord](nil, nil); err == nil {
+		t.Errorf("expected an error for a nil query")
+	}
+	if _, err := Compile("Age > 30 AND )"); err == nil {
+		t.Errorf("expected a compile error")
+	}
+}
+
+func TestRestrict(t *testing.T) {
+	records := []tenantRecord{
+		{TenantID: 1, Name: "a", Tags: []int{1}},
+		{TenantID: 1, Name: "b", Tags: []int{2}},
+		{TenantID: 2, Name: "c", Tags: []int{1, 2}},
+		{TenantID: 3, Name: "d"},
+	}
+
+	// The trusted predicate may use a field users are not allowed to query
+	trusted, err := Compile("TenantID = 1")
+	if err != nil {
+		t.Fatalf("Compile returned error: %v", err)
+	}
+	userOpts := []Option{WithDeniedFields("TenantID")}
+
+	escapes := []string{
+		"",
+		"Name = 'a'",
+		"Name = 'a' OR Name != 'a'",
+		"OR Name = 'c'",
+		"Name = 'c' OR",
+		"Name = 'c') OR (Name = 'c'",
+		"Name = 'c')) OR ((Name = 'c'",
+		") OR (",
+		"Name = 'x' OR NOT Name = 'x'",
+		"NOT (Name = 'a')",
+		"Tags = 2",
+		"Name = 'c' OR TenantID = 2",
+		"TenantID != 1",
+		"tenantid = 2 OR tenantid = 3",
+		"ANY(TenantID) = ANY(2, 3)",
+		"Name = 'a\\' OR TenantID = 2 OR Name = \\''",
+		"Name = 'a' -- OR TenantID = 2",
+		"Name = '' OR '' = ''",
+		"(((((Name = 'c')))))",
+	}
+
+	for _, input := range escapes {
+		t.Run(input, func(t *testing.T) {
+			user, err := Compile(input, userOpts...)
+			if err != nil {
+				return // rejected outright
+			}
+			q, err := Restrict(user, trusted)
+			if err != nil {
+				t.Fatalf("Restrict returned error: %v", err)
+			}
+			results, err := Filter(q, records)
+			if err != nil {
+				if errors.Is(err, ErrFieldNotAllowed) {
+					t.Fatalf("trusted predicate was checked against the user's options: %v", err)
+				}
+				return
+			}
+			for _, r := range results {
+				if r.TenantID != 1 {
+					t.Errorf("query %q returned record %+v from another tenant", input, r)
+				}
+			}
+		})
+	}
+
+	user, _ := Compile("Name = 'b' OR Name = 'c'")
+	q, err := Restrict(user, trusted)
+	if err != nil {
+		t.Fatalf("Restrict returned error: %v", err)
+	}
+	results, err := Filter(q, records)
+	if err != nil {
+		t.Fatalf("Filter returned error: %v", err)
+	}
+	if len(results) != 1 || results[0].Name != "b" {
+		t.Errorf("expected only record b, got %+v", results)
+	}
+
+	empty, _ := Compile("")
+	if _, err := Restrict(user, empty); err == nil {
+		t.Errorf("expected an error for an empty trusted predicate")
+	}
+	if _, err := Restrict(user, nil); err == nil {
+		t.Errorf("expected an error for a nil trusted predicate")
+	}
+}
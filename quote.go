@@ -0,0 +1,130 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// scanString decodes the string literal starting with the quote at s[start], which
+// may be a single or double quote. Inside it, the quote can be written twice, SQL
+// style, or escaped, and the escapes \\ \' \" \n \t \r and \uXXXX are
+// recognised. A backslash before any other character is kept as it is, so
+// 'C:\path' reads as written. end is the index just past the closing quote, also
+// when a \u escape is invalid and err reports it. An unclosed string returns an
+// error and end is len(s).
+func scanString(s string, start int) (value string, end int, err error) {
+	quote := s[start]
+	var b strings.Builder
+	var escapeErr error
+	i := start + 1
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == quote:
+			if i+1 < len(s) && s[i+1] == quote {
+				b.WriteByte(quote)
+				i += 2
+				continue
+			}
+			if escapeErr != nil {
+				return "", i + 1, escapeErr
+			}
+			return b.String(), i + 1, nil
+		case c == '\\' && i+1 < len(s):
+			n, err := decodeEscape(s[i:], &b)
+			if err != nil && escapeErr == nil {
+				escapeErr = err
+			}
+			i += n
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return "", len(s), fmt.Errorf("unclosed string: %s", s[start+1:])
+}
+
+// decodeEscape writes the escape sequence at the start of s to b and returns its
+// length. A backslash that starts no escape is written as it is. An invalid \u
+// escape returns an error, and the length of the backslash and the u.
+func decodeEscape(s string, b *strings.Builder) (int, error) {
+	switch s[1] {
+	case '\\', '\'', '"':
+		b.WriteByte(s[1])
+	case 'n':
+		b.WriteByte('\n')
+	case 't':
+		b.WriteByte('\t')
+	case 'r':
+		b.WriteByte('\r')
+	case 'u':
+		r, ok := hexRune(s[2:])
+		if !ok {
+			return 2, fmt.Errorf("invalid unicode escape: %.6s", s)
+		}
+		// Characters outside the BMP are written as a UTF-16 surrogate pair
+		if utf16.IsSurrogate(r) {
+			if len(s) >= 12 && s[6] == '\\' && s[7] == 'u' {
+				if low, ok := hexRune(s[8:]); ok {
+					if pair := utf16.DecodeRune(r, low); pair != utf8.RuneError {
+						b.WriteRune(pair)
+						return 12, nil
+					}
+				}
+			}
+			return 2, fmt.Errorf("invalid unicode escape: %.6s", s)
+		}
+		b.WriteRune(r)
+		return 6, nil
+	default:
+		b.WriteByte('\\')
+		return 1, nil
+	}
+	return 2, nil
+}
+
+// hexRune parses the four hex digits at the start of s
+func hexRune(s string) (rune, bool) {
+	if len(s) < 4 {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s[:4], 16, 32)
+	if err != nil {
+		return 0, false
+	}
+	return rune(n), true
+}
+
+// Quote returns s as a single-quoted string literal that the parser reads back as
+// exactly s. Prefer bind parameters where possible; Quote is for code that has to
+// build query text.
+func Quote(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('\'')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '\\', '\'':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(&b, `\u%04x`, c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
@@ -0,0 +1,109 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStringLiterals(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr string
+	}{
+		{`'plain'`, "plain", ""},
+		{`"double"`, "double", ""},
+		{`''`, "", ""},
+		{`""`, "", ""},
+		{`'it''s'`, "it's", ""},
+		{`"say ""hi"""`, `say "hi"`, ""},
+		{`'it\'s'`, "it's", ""},
+		{`"a\"b"`, `a"b`, ""},
+		{`'a"b'`, `a"b`, ""},
+		{`"a'b"`, "a'b", ""},
+		{`'back\\slash'`, `back\slash`, ""},
+		{`'ends with \\'`, `ends with \`, ""},
+		{`'line\nbreak\ttab\rcr'`, "line\nbreak\ttab\rcr", ""},
+		{`'été'`, "été", ""},
+		{`'😀'`, "😀", ""},
+		{`'10m and 5GB'`, "10m and 5GB", ""},
+		{`'unclosed`, "", "unclosed string"},
+		{`'unclosed\'`, "", "unclosed string"},
+		{`"mixed'`, "", "unclosed string"},
+		{`'C:\path\x'`, `C:\path\x`, ""},
+		{`'trailing \ '`, `trailing \ `, ""},
+		{`'bad \u12'`, "", "invalid unicode escape"},
+		{`'lone \ud83d'`, "", "invalid unicode escape"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			tok := NewEnhancedLexer(normalizeHumanizedValues(tt.input)).NextToken()
+			if tt.wantErr != "" {
+				if tok.Type != ILLEGAL || !strings.Contains(tok.Literal, tt.wantErr) {
+					t.Errorf("got %s %q, want ILLEGAL containing %q", tok.Type, tok.Literal, tt.wantErr)
+				}
+				return
+			}
+			if tok.Type != STRING || tok.Literal != tt.want {
+				t.Errorf("got %s %q, want STRING %q", tok.Type, tok.Literal, tt.want)
+			}
+		})
+	}
+}
+
+func TestStringEscapeErrors(t *testing.T) {
+	type record struct{ Name string }
+	data := []record{{Name: `C:\path`}, {Name: "other"}}
+
+	results, err := Parse(`Name = 'C:\path'`, data)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if len(results) != 1 || results[0].Name != `C:\path` {
+		t.Errorf("expected only C:\\path, got %v", results)
+	}
+
+	// The error is the escape's, at the string, and lexing resumes after the string
+	_, err = Parse(`Name = 'bad \u12 escape' AND Name = 'x'`, data)
+	if err == nil || !strings.Contains(err.Error(), `invalid unicode escape: \u12 e (column 8)`) {
+		t.Errorf("expected an invalid unicode escape error at column 8, got %v", err)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	values := []string{
+		"",
+		"plain",
+		"it's",
+		`say "hi"`,
+		`back\slash`,
+		`\'`,
+		`'\`,
+		"' OR Name = 'x",
+		"line\nbreak\ttab\rcr",
+		"nul\x00bell\x07del\x7f",
+		"été 😀",
+		"10m 5GB 1,000",
+		"\\u0041",
+	}
+
+	type record struct{ Name string }
+	for _, v := range values {
+		t.Run(v, func(t *testing.T) {
+			tok := NewEnhancedLexer(normalizeHumanizedValues(Quote(v))).NextToken()
+			if tok.Type != STRING || tok.Literal != v {
+				t.Fatalf("Quote(%q) = %s, read back as %s %q", v, Quote(v), tok.Type, tok.Literal)
+			}
+
+			data := []record{{Name: v}, {Name: v + "x"}}
+			results, err := Parse("EXACT(Name) = "+Quote(v), data)
+			if err != nil {
+				t.Fatalf("Parse returned error: %v", err)
+			}
+			if len(results) != 1 || results[0].Name != v {
+				t.Errorf("expected only %q, got %v", v, results)
+			}
+		})
+	}
+}
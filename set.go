@@ -0,0 +1,321 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// SetExpression compares the elements of a slice field as a whole with a list of
+// values or the elements of another field:
+//
+//	Skills CONTAINS ALL ('Go', 'Rust')   every value is an element
+//	Skills CONTAINS ANY ('Go', 'Java')   some value is an element
+//	Skills CONTAINS ONLY ('Go', 'Rust')  every element is one of the values
+//	Skills OVERLAPS Team.Skills          the two share an element
+//	Skills = ('Go', 'Python')            the same elements, in any order
+//	Skills = ['Go', 'Python']            the same elements in the same order
+//
+// Elements are compared as in ANY(Field) = ANY(...), so numbers compare by value
+// and strings are case-sensitive unless a collation is set. Duplicates don't
+// matter except in ordered comparisons.
+type SetExpression struct {
+	Field    string
+	Operator TokenType // ALL, ANY or ONLY for CONTAINS, OVERLAPS, EQ or NE
+	Ordered  bool      // compare element by element, for [...] lists
+
+	// List holds the values compared with; Other is the field compared with
+	// instead when List is nil
+	List  *AnyExpression
+	Other string
+
+	opts *options
+}
+
+// setValue is a value on the right-hand side of a set operator
+type setValue struct {
+	value string
+	num   *numericLiteral
+	arg   any
+}
+
+// newSetValue returns value, with the Go value arg it was bound from if any
+func newSetValue(value string, arg any) setValue {
+	return setValue{value, literalNumber(value), arg}
+}
+
+// Evaluate for SetExpression. An empty or nil slice is the empty set.
+func (se *SetExpression) Evaluate(item reflect.Value) (bool, error) {
+	elems, err := se.elements(item, se.Field)
+	if err != nil {
+		return false, err
+	}
+	values, err := se.values(item)
+	if err != nil {
+		return false, err
+	}
+
+	// Elements compare with values as ANY(Field) = ANY(value) would. An element
+	// fails only if it can't be compared with any of the values, so lists can mix
+	// types. If another element could be compared it then matches no value, as in
+	// a mixed []any; otherwise the failure is settled as there.
+	matcher := se.List
+	if matcher == nil {
+		matcher = &AnyExpression{Field: se.Field, Operator: EQ, opts: se.opts}
+	}
+	matches := make([][]bool, len(elems))
+	var lastError error
+	comparedAny := false
+	for i, elem := range elems {
+		matches[i] = make([]bool, len(values))
+		var elemError error
+		compared := false
+		for j, v := range values {
+			ok, err := matcher.compareValue(elem, v.value, v.num, v.arg)
+			if err != nil {
+				elemError = err
+				continue
+			}
+			matches[i][j], compared = ok, true
+		}
+		if !compared && elemError != nil {
+			lastError = elemError
+		}
+		comparedAny = comparedAny || compared
+	}
+	if comparedAny {
+		lastError = nil
+	}
+	match := func(i, j int) bool { return matches[i][j] }
+
+	var result bool
+	n, m := len(elems), len(values)
+	switch se.Operator {
+	case ANY, OVERLAPS:
+		result = anyPair(n, m, match)
+	case ALL:
+		result = everyValueIn(n, m, match)
+	case ONLY:
+		result = everyElementIn(n, m, match)
+	case EQ, NE:
+		if se.Ordered {
+			result = sameOrder(n, m, match)
+		} else {
+			result = everyValueIn(n, m, match) && everyElementIn(n, m, match)
+		}
+		if se.Operator == NE {
+			result = !result
+		}
+	default:
+		return false, fmt.Errorf("unsupported set operator %s", se.Operator)
+	}
+
+	if lastError == nil {
+		return result, nil
+	}
+	return se.opts.settle(result, false, lastError)
+}
+
+// elements returns the elements of the slice at path, without nil pointers
+func (se *SetExpression) elements(item reflect.Value, path string) ([]reflect.Value, error) {
+	values, err := getFieldValues(item, path, se.opts)
+	if err != nil {
+		if !errors.Is(err, errFieldNotFound) {
+			return nil, err
+		}
+		// A missing slice is NULL with MissingFieldLenient, which is unknown
+		return nil, se.opts.fail(fmt.Errorf("field '%s' not found", path))
+	}
+	if isNullSlice(values) {
+		return nil, ErrUnknown // a NULL slice
+	}
+	elems := values[:0:0]
+	for _, v := range values {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			continue
+		}
+		elems = append(elems, v)
+	}
+	return elems, nil
+}
+
+// values returns the right-hand side: the list, or the elements of Other as literals
+func (se *SetExpression) values(item reflect.Value) ([]setValue, error) {
+	if se.List != nil {
+		values := make([]setValue, len(se.List.Values))
+		for i, value := range se.List.Values {
+			values[i] = setValue{value, se.List.nums[i], se.List.arg(i)}
+		}
+		return values, nil
+	}
+
+	elems, err := se.elements(item, se.Other)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]setValue, 0, len(elems))
+	for _, elem := range elems {
+		v, ok, err := elementLiteral(elem)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read value of field '%s': %w", se.Other, err)
+		}
+		if ok {
+			values = append(values, v)
+		}
+	}
+	return values, nil
+}
+
+// elementLiteral renders an element of a field as a value, the way bind parameters
+// are. NULL elements report false.
+func elementLiteral(elem reflect.Value) (setValue, bool, error) {
+	for elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Interface {
+		if elem.IsNil() {
+			return setValue{}, false, nil
+		}
+		elem = elem.Elem()
+	}
+	elem, isNull, err := resolveValuer(elem)
+	if err != nil || isNull {
+		return setValue{}, false, err
+	}
+
+	if elem.CanInterface() {
+		arg := elem.Interface()
+		lit, err := bindLiteral(arg)
+		if err != nil {
+			return setValue{}, false, err
+		}
+		return newSetValue(lit, arg), true, nil
+	}
+
+	// Unexported values can't be passed on as Go values, only by their contents
+	switch elem.Kind() {
+	case reflect.String:
+		return newSetValue(elem.String(), nil), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return newSetValue(strconv.FormatInt(elem.Int(), 10), nil), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return newSetValue(strconv.FormatUint(elem.Uint(), 10), nil), true, nil
+	case reflect.Float32, reflect.Float64:
+		return newSetValue(strconv.FormatFloat(elem.Float(), 'g', -1, 64), nil), true, nil
+	case reflect.Bool:
+		return newSetValue(strconv.FormatBool(elem.Bool()), nil), true, nil
+	}
+	return setValue{}, false, fmt.Errorf("unsupported element type %s", elem.Type())
+}
+
+// The set operators below take the number of elements n and values m, and whether
+// element i matches value j.
+
+// anyPair reports whether some element matches some value
+func anyPair(n, m int, match func(i, j int) bool) bool {
+	for j := 0; j < m; j++ {
+		if containsValue(n, j, match) {
+			return true
+		}
+	}
+	return false
+}
+
+// everyValueIn reports whether every value matches an element
+func everyValueIn(n, m int, match func(i, j int) bool) bool {
+	for j := 0; j < m; j++ {
+		if !containsValue(n, j, match) {
+			return false
+		}
+	}
+	return true
+}
+
+// everyElementIn reports whether every element matches a value
+func everyElementIn(n, m int, match func(i, j int) bool) bool {
+	for i := 0; i < n; i++ {
+		found := false
+		for j := 0; j < m; j++ {
+			if match(i, j) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// sameOrder reports whether the elements match the values one to one, in order
+func sameOrder(n, m int, match func(i, j int) bool) bool {
+	if n != m {
+		return false
+	}
+	for i := 0; i < n; i++ {
+		if !match(i, i) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsValue(n, j int, match func(i, j int) bool) bool {
+	for i := 0; i < n; i++ {
+		if match(i, j) {
+			return true
+		}
+	}
+	return false
+}
+
+// atSetOperator reports whether the tokens after a field start a set operator
+func (p *Parser) atSetOperator() bool {
+	switch p.currentToken.Type {
+	case CONTAINS:
+		return p.peekToken.Type == ALL || p.peekToken.Type == ANY || p.peekToken.Type == ONLY
+	case OVERLAPS:
+		return true
+	case EQ, NE:
+		return p.peekToken.Type == LPAREN || p.peekToken.Type == LBRACKET
+	}
+	return false
+}
+
+// parseSetExpression parses a set operator and its list or field after field
+func (p *Parser) parseSetExpression(field string) Expression {
+	se := &SetExpression{Field: field, Operator: p.currentToken.Type, opts: p.opts}
+	name := p.currentToken.Literal
+	if se.Operator == CONTAINS {
+		p.nextToken() // consume CONTAINS
+		se.Operator = p.currentToken.Type
+		name = "CONTAINS " + string(se.Operator)
+	}
+	p.nextToken() // consume the operator
+
+	switch {
+	case p.currentTokenIs(LPAREN), p.currentTokenIs(LBRACKET):
+		closing := RPAREN
+		if p.currentTokenIs(LBRACKET) {
+			if se.Operator != EQ && se.Operator != NE {
+				p.errorf("expected '(' after %s; [...] lists are ordered and only compare with = and !=", name)
+				return nil
+			}
+			se.Ordered, closing = true, RBRACKET
+		}
+		p.nextToken() // consume '(' or '['
+		se.List = &AnyExpression{Field: field, Operator: EQ, opts: p.opts}
+		if !p.parseValueList(se.List, name, closing) {
+			return nil
+		}
+	case p.currentTokenIs(IDENTIFIER):
+		se.Other = p.currentToken.Literal
+		if !p.checkField(se.Other) {
+			return nil
+		}
+		p.nextToken() // consume field
+	default:
+		p.errorf("expected list of values or field after %s", name)
+		return nil
+	}
+	return se
+}
@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+type setTeam struct {
+	Skills []string
+}
+
+type setPerson struct {
+	Name   string
+	Skills []string
+	Scores []int
+	Tags   []any
+	Team   setTeam
+}
+
+func TestSetOperators(t *testing.T) {
+	people := []setPerson{
+		{Name: "ann", Skills: []string{"Go", "Rust"}, Scores: []int{1, 2, 3}, Tags: []any{"a", 1}, Team: setTeam{Skills: []string{"Java"}}},
+		{Name: "bob", Skills: []string{"Go", "Python", "Go"}, Scores: []int{3, 2, 1}, Tags: []any{2.5, "b"}, Team: setTeam{Skills: []string{"Python", "C"}}},
+		{Name: "cy", Skills: []string{}, Scores: nil, Tags: nil, Team: setTeam{Skills: []string{"Go"}}},
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"CONTAINS ALL", "Skills CONTAINS ALL ('Go', 'Rust')", []string{"ann"}},
+		{"CONTAINS ALL single", "Skills CONTAINS ALL ('Go')", []string{"ann", "bob"}},
+		{"CONTAINS ANY", "Skills CONTAINS ANY ('Rust', 'Python')", []string{"ann", "bob"}},
+		{"CONTAINS ANY is case-sensitive", "Skills CONTAINS ANY ('go')", nil},
+		{"CONTAINS ONLY", "Skills CONTAINS ONLY ('Go', 'Python', 'C')", []string{"bob", "cy"}},
+		{"OVERLAPS list", "Skills OVERLAPS ('Rust', 'Java')", []string{"ann"}},
+		{"OVERLAPS field", "Skills OVERLAPS Team.Skills", []string{"bob"}},
+		{"NOT OVERLAPS field", "NOT Skills OVERLAPS Team.Skills", []string{"ann", "cy"}},
+		{"CONTAINS ONLY nested field", "Team.Skills CONTAINS ONLY ('Go', 'Python', 'C')", []string{"bob", "cy"}},
+		{"Set equality ignores order and duplicates", "Skills = ('Python', 'Go')", []string{"bob"}},
+		{"Set inequality", "Skills != ('Rust', 'Go')", []string{"bob", "cy"}},
+		{"Ordered equality", "Scores = [1, 2, 3]", []string{"ann"}},
+		{"Ordered equality is ordered", "Scores = [3, 2, 1]", []string{"bob"}},
+		{"Ordered inequality", "Scores != [1, 2, 3]", []string{"bob"}}, // cy's nil Scores are NULL
+		{"Ordered strings", "Skills = ['Go', 'Rust']", []string{"ann"}},
+		{"Ordered equality without spaces", "Scores = [1,2,3]", []string{"ann"}},
+		{"CONTAINS ANY without spaces", "Scores CONTAINS ANY (1,2)", []string{"ann", "bob"}},
+		{"Set equality without spaces", "Scores = (3,2,1)", []string{"ann", "bob"}},
+		{"Numbers compare by value", "Scores CONTAINS ALL (1.0, 3)", []string{"ann", "bob"}},
+		{"Interface slices", "Tags CONTAINS ANY ('b', 1)", []string{"ann", "bob"}},
+		{"Interface numbers", "Tags CONTAINS ALL (2.50)", []string{"bob"}},
+		{"Interface set equality", "Tags = (1, 'a')", []string{"ann"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := Parse(tt.query, people)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.query, err)
+			}
+			checkNames(t, tt.query, results, tt.want)
+		})
+	}
+}
+
+func TestSetOperatorOptions(t *testing.T) {
+	people := []setPerson{
+		{Name: "ann", Skills: []string{"Go", "Rust"}, Scores: []int{1, 2, 3}},
+		{Name: "bob", Skills: []string{"Go", "Python"}, Scores: []int{3, 2, 1}},
+	}
+
+	results, err := Parse("Skills CONTAINS ALL ('GO', 'rust')", people, WithCollation(CollationSimpleFold))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Name != "ann" {
+		t.Errorf("collation: got %v, want [ann]", results)
+	}
+
+	q, err := Compile("Skills CONTAINS ANY (?) AND Scores = [?, 2, ?]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err = q.Bind([]string{"Rust", "C"}, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err = Filter(q, people)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Name != "ann" {
+		t.Errorf("bind: got %v, want [ann]", results)
+	}
+}
+
+func TestSetOperatorErrors(t *testing.T) {
+	people := []setPerson{{Name: "ann", Skills: []string{"Go"}, Team: setTeam{Skills: []string{"Java"}}}}
+
+	tests := []struct {
+		name  string
+		query string
+		opts  []Option
+		want  string
+	}{
+		{"Empty list", "Skills CONTAINS ALL ()", nil, "expected string or number value in CONTAINS ALL"},
+		{"Unclosed bracket", "Scores = [1, 2", nil, "expected ']'"},
+		{"Ordered CONTAINS", "Skills CONTAINS ANY ['Go']", nil, "only compare with = and !="},
+		{"Missing operand", "Skills OVERLAPS 'Go'", nil, "expected list of values or field after OVERLAPS"},
+		{"Unknown other field", "Skills OVERLAPS Team.Languages", nil, "not found"},
+		{"Denied other field", "Skills OVERLAPS Team.Skills", []Option{WithDeniedFields("Team.*")}, "not allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.query, people, tt.opts...)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Parse(%q) error = %v, want it to contain %q", tt.query, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+)
+
+// IsTypeExpression tests the concrete type of a value, for slices of interfaces
+// such as []Event: IS LoginEvent tests the item itself, Payload IS LoginEvent a
+// field. Type names match case-insensitively, by name or qualified by package.
+type IsTypeExpression struct {
+	Field string // empty for the item itself
+	Type  string
+	Not   bool
+
+	opts *options
+}
+
+// Evaluate for IsTypeExpression. A nil value has no type, so it is never of the
+// type, and IS NOT matches it.
+func (te *IsTypeExpression) Evaluate(item reflect.Value) (bool, error) {
+	values := []reflect.Value{item}
+	if te.Field != "" {
+		var err error
+		if values, err = lookupField(item, te.Field, te.opts); err != nil {
+			return false, err
+		}
+	}
+	for _, v := range values {
+		for _, t := range concreteTypes(v) {
+			if strings.EqualFold(typeName(t), te.Type) || strings.EqualFold(t.String(), te.Type) {
+				return !te.Not, nil
+			}
+		}
+	}
+	return te.Not, nil
+}
+
+// concreteTypes returns the concrete types of v: one for a value, or one for each
+// element of a slice or array. Nil values have no type.
+func concreteTypes(v reflect.Value) []reflect.Type {
+	for (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && !v.IsNil() {
+		v = v.Elem()
+	}
+	if !v.IsValid() || v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		return nil
+	}
+	if (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && !hasTextForm(v.Type()) {
+		var types []reflect.Type
+		for i := 0; i < v.Len(); i++ {
+			types = append(types, concreteTypes(v.Index(i))...)
+		}
+		return types
+	}
+	return []reflect.Type{v.Type()}
+}
+
+// typeNames returns the names of the concrete types of v, for TYPE(...)
+func typeNames(v reflect.Value) []reflect.Value {
+	var names []reflect.Value
+	for _, t := range concreteTypes(v) {
+		names = append(names, reflect.ValueOf(typeName(t)))
+	}
+	return names
+}
+
+// typeName returns the name of t without its package, such as LoginEvent, or its
+// Go syntax if it has no name, such as map[string]interface {}
+func typeName(t reflect.Type) string {
+	if t.Name() != "" {
+		return t.Name()
+	}
+	return t.String()
+}
+
+// parseIsType parses the type name after IS or IS NOT, for field or, if field is
+// empty, the item itself
+func (p *Parser) parseIsType(field string, not bool) Expression {
+	if !p.currentTokenIs(IDENTIFIER) {
+		p.errorf("expected NULL or a type name after IS")
+		return nil
+	}
+	te := &IsTypeExpression{Field: field, Type: p.currentToken.Literal, Not: not, opts: p.opts}
+	if strings.ContainsAny(te.Type, "`[]$*()") {
+		p.errorf("invalid type name %s after IS", te.Type)
+		return nil
+	}
+	p.nextToken() // consume the type name
+	return te
+}
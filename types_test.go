@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type tEvent interface {
+	ID() string
+}
+
+type tLoginEvent struct {
+	Name   string
+	UserID int
+	IP     string
+}
+
+type tLogoutEvent struct {
+	Name   string
+	UserID int
+}
+
+type tErrorEvent struct {
+	Name string
+	Code int
+	Addr string `parser:"origin"`
+}
+
+func (e tLoginEvent) ID() string   { return e.Name }
+func (e *tLogoutEvent) ID() string { return e.Name }
+func (e tErrorEvent) ID() string   { return e.Name }
+
+type tSession struct {
+	Name   string
+	Events []tEvent
+}
+
+func TestTypePredicates(t *testing.T) {
+	events := []tEvent{
+		tLoginEvent{Name: "login", UserID: 5, IP: "10.0.0.1"},
+		&tLogoutEvent{Name: "logout", UserID: 5},
+		tErrorEvent{Name: "error", Code: 500, Addr: "db"},
+		nil,
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		opts  []Option
+		want  []string
+	}{
+		{"TYPE()", "TYPE() = 'tLoginEvent'", nil, []string{"login"}},
+		{"TYPE() of a pointer", "TYPE() = 'tLogoutEvent'", nil, []string{"logout"}},
+		{"TYPE() IN", "TYPE() IN ('tLoginEvent', 'tErrorEvent')", nil, []string{"login", "error"}},
+		{"IS", "IS tLoginEvent", nil, []string{"login"}},
+		{"IS case-insensitive", "is tlogoutevent", nil, []string{"logout"}},
+		{"IS qualified", "IS parser.tErrorEvent", nil, []string{"error"}},
+		{"IS wrong package", "IS other.tErrorEvent", nil, nil},
+		{"IS NOT", "IS NOT tLoginEvent", nil, []string{"logout", "error"}},
+		{"NOT IS", "NOT IS tLoginEvent AND Name != 'error'", nil, []string{"logout"}},
+		{"Guarded field", "IS tErrorEvent AND Code = 500", nil, []string{"error"}},
+		{"Shared field", "UserID = 5 AND IS NOT tLoginEvent", []Option{WithMissingFields(MissingFieldLenient)}, []string{"logout"}},
+		{"Lenient missing field", "Code = 500", []Option{WithMissingFields(MissingFieldLenient)}, []string{"error"}},
+		{"Lenient missing is NULL", "Code IS NULL", []Option{WithMissingFields(MissingFieldLenient)}, []string{"login", "logout"}},
+		{"TYPE() with allow list", "TYPE() = 'tErrorEvent'", []Option{WithAllowedFields("Name")}, []string{"error"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := Parse(tt.query, events, tt.opts...)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.query, err)
+			}
+			checkNames(t, tt.query, results, tt.want)
+		})
+	}
+}
+
+func TestTypePredicatesOnFields(t *testing.T) {
+	sessions := []tSession{
+		{Name: "a", Events: []tEvent{tLoginEvent{IP: "10.0.0.1"}, &tLogoutEvent{}}},
+		{Name: "b", Events: []tEvent{tErrorEvent{Code: 503}, nil}},
+		{Name: "c", Events: []tEvent{tLoginEvent{IP: "10.0.0.2"}}},
+	}
+	tests := []struct {
+		name  string
+		query string
+		opts  []Option
+		want  []string
+	}{
+		{"Field IS", "Events IS tErrorEvent", nil, []string{"b"}},
+		{"TYPE(Field)", "TYPE(Events) = 'tLogoutEvent'", nil, []string{"a"}},
+		{"Quantifier", "Events ANY (IS tLoginEvent AND IP = '10.0.0.1')", nil, []string{"a"}},
+		{"Quantifier TYPE()", "Events ALL (TYPE() != 'tErrorEvent')", nil, []string{"a", "c"}},
+		{"Lenient quantifier", "Events ANY (Code >= 500)", []Option{WithMissingFields(MissingFieldLenient)}, []string{"b"}},
+		{"Lenient set", "Events.Code CONTAINS ANY (503)", []Option{WithMissingFields(MissingFieldLenient)}, []string{"b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := Parse(tt.query, sessions, tt.opts...)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.query, err)
+			}
+			checkNames(t, tt.query, results, tt.want)
+		})
+	}
+}
+
+func TestTypePredicateErrors(t *testing.T) {
+	events := []tEvent{tLoginEvent{Name: "login"}, tErrorEvent{Name: "error", Code: 500, Addr: "db"}}
+
+	// Strict is the default: a field one of the types lacks fails the query
+	if _, err := Parse("Code = 500", events); err == nil || !strings.Contains(err.Error(), "field 'Code' not found") {
+		t.Errorf("expected field not found, got %v", err)
+	}
+
+	// Concrete types behind an interface are checked against allow and deny lists
+	// under their Go names too. A deny list rejects the alias of a denied field but
+	// not an unknown name, which reveals the alias exists; that is documented on
+	// WithDeniedFields, and an allow list rejects both alike.
+	_, err := Parse("origin = 'db'", events, WithMissingFields(MissingFieldLenient), WithDeniedFields("Addr"))
+	if !errors.Is(err, ErrFieldNotAllowed) {
+		t.Errorf("expected ErrFieldNotAllowed for a tag alias of a denied field, got %v", err)
+	}
+	_, errAlias := Parse("origin = 'db'", events, WithAllowedFields("Name"))
+	_, errUnknown := Parse("nowhere = 'db'", events, WithAllowedFields("Name"))
+	if !errors.Is(errAlias, ErrFieldNotAllowed) || !errors.Is(errUnknown, ErrFieldNotAllowed) {
+		t.Errorf("expected ErrFieldNotAllowed for an alias and an unknown name, got %v and %v", errAlias, errUnknown)
+	}
+
+	for _, query := range []string{"IS 'tLoginEvent'", "Name IS", "IS TYPE()"} {
+		if _, err := Parse(query, events); err == nil {
+			t.Errorf("Parse(%q) expected a parse error", query)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Unit is a single suffix within a UnitFamily, e.g. "KiB" with a multiplier of 1024.
+type Unit struct {
+	Suffix     string
+	Multiplier float64
+}
+
+// UnitFamily groups related unit suffixes (time, bytes, currency, ...) that share
+// case sensitivity and precedence.
+type UnitFamily struct {
+	Name  string
+	Units []Unit
+
+	// CaseInsensitive makes every suffix in the family match regardless of case.
+	CaseInsensitive bool
+
+	// Precedence decides which family wins when a suffix matches in more than one
+	// family. Lower values are tried first. Families with equal precedence must not
+	// overlap; Register rejects them.
+	Precedence int
+}
+
+// UnitRegistry holds the unit families used to convert humanized literals such as
+// "10m", "2GiB" or "1.5K" into plain numbers before a query is lexed.
+type UnitRegistry struct {
+	mu       sync.RWMutex
+	families []registeredFamily
+	// extra holds the non-alphanumeric characters used by registered suffixes
+	// (e.g. '%' or '€'), so the normalizer knows they belong to a number token.
+	extra string
+}
+
+// registeredFamily keeps the exact value of each multiplier next to the family
+type registeredFamily struct {
+	UnitFamily
+	multipliers []*big.Rat
+}
+
+// DefaultUnits is the registry used by Parse unless WithUnitRegistry is passed. It
+// ships with the time, bytes and si families.
+var DefaultUnits = newDefaultUnitRegistry()
+
+// RegisterUnitFamily adds a unit family to DefaultUnits.
+func RegisterUnitFamily(f UnitFamily) error {
+	return DefaultUnits.Register(f)
+}
+
+// NewUnitRegistry returns an empty registry. Use DefaultUnits.Clone to start from the
+// built-in families instead.
+func NewUnitRegistry() *UnitRegistry {
+	return &UnitRegistry{}
+}
+
+// WithUnitRegistry converts humanized literals with r instead of DefaultUnits, so
+// units can be added for some queries without changing how every query parses.
+func WithUnitRegistry(r *UnitRegistry) Option {
+	return func(o *options) {
+		o.units = r
+	}
+}
+
+// unitsOrDefault returns the registry set by WithUnitRegistry, or DefaultUnits
+func (o *options) unitsOrDefault() *UnitRegistry {
+	if o == nil || o.units == nil {
+		return DefaultUnits
+	}
+	return o.units
+}
+
+// Clone returns a copy of the registry. Families registered on the copy don't
+// affect r, and the other way round.
+func (r *UnitRegistry) Clone() *UnitRegistry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return &UnitRegistry{
+		families: append([]registeredFamily(nil), r.families...),
+		extra:    r.extra,
+	}
+}
+
+func newDefaultUnitRegistry() *UnitRegistry {
+	r := NewUnitRegistry()
+	defaults := []UnitFamily{
+		{
+			// Time units are converted to seconds; sub-second units give exact fractions
+			Name:       "time",
+			Precedence: 10,
+			Units: []Unit{
+				{"ns", 1e-9},
+				{"us", 1e-6},
+				{"µs", 1e-6}, // micro sign
+				{"μs", 1e-6}, // Greek mu
+				{"ms", 1e-3},
+				{"s", 1},
+				{"m", 60},
+				{"h", 3600},
+				{"d", 86400},
+				{"w", 604800},
+				{"y", 31536000}, // 365 days
+			},
+		},
+		{
+			// Decimal (base 1000) and binary (base 1024) byte sizes
+			Name:       "bytes",
+			Precedence: 20,
+			Units: []Unit{
+				{"B", 1},
+				{"KB", 1e3},
+				{"MB", 1e6},
+				{"GB", 1e9},
+				{"TB", 1e12},
+				{"PB", 1e15},
+				{"EB", 1e18},
+				{"KiB", 1 << 10},
+				{"MiB", 1 << 20},
+				{"GiB", 1 << 30},
+				{"TiB", 1 << 40},
+				{"PiB", 1 << 50},
+				{"EiB", 1 << 60},
+			},
+		},
+		{
+			// SI prefixes are uppercase only to avoid conflicts with time units
+			Name:       "si",
+			Precedence: 30,
+			Units: []Unit{
+				{"K", 1e3},
+				{"M", 1e6},
+				{"G", 1e9},
+				{"T", 1e12},
+				{"P", 1e15},
+				{"E", 1e18},
+			},
+		},
+	}
+	for _, f := range defaults {
+		if err := r.Register(f); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// Register adds a unit family to the registry. It returns an error if the family is
+// malformed, its name is already taken, or one of its suffixes would match the same
+// text as a suffix of a family with the same precedence.
+func (r *UnitRegistry) Register(f UnitFamily) error {
+	if f.Name == "" {
+		return fmt.Errorf("unit family must have a name")
+	}
+	if len(f.Units) == 0 {
+		return fmt.Errorf("unit family %q has no units", f.Name)
+	}
+
+	for i, u := range f.Units {
+		if u.Suffix == "" {
+			return fmt.Errorf("unit family %q: empty suffix", f.Name)
+		}
+		if strings.ContainsAny(u.Suffix, "0123456789.,'\" \t") {
+			return fmt.Errorf("unit family %q: invalid suffix %q", f.Name, u.Suffix)
+		}
+		if u.Multiplier == 0 || math.IsNaN(u.Multiplier) || math.IsInf(u.Multiplier, 0) {
+			return fmt.Errorf("unit family %q: suffix %q has an invalid multiplier", f.Name, u.Suffix)
+		}
+		for _, other := range f.Units[:i] {
+			if suffixesOverlap(u.Suffix, f.CaseInsensitive, other.Suffix, f.CaseInsensitive) {
+				return fmt.Errorf("unit family %q: suffix %q conflicts with %q", f.Name, u.Suffix, other.Suffix)
+			}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.families {
+		if strings.EqualFold(existing.Name, f.Name) {
+			return fmt.Errorf("unit family %q is already registered", f.Name)
+		}
+		if existing.Precedence != f.Precedence {
+			continue
+		}
+		for _, u := range f.Units {
+			for _, eu := range existing.Units {
+				if suffixesOverlap(u.Suffix, f.CaseInsensitive, eu.Suffix, existing.CaseInsensitive) {
+					return fmt.Errorf("unit %q of family %q conflicts with unit %q of family %q at precedence %d",
+						u.Suffix, f.Name, eu.Suffix, existing.Name, f.Precedence)
+				}
+			}
+		}
+	}
+
+	// Whole multipliers such as 1<<60 are exact as floats; fractional ones are taken
+	// at their shortest decimal representation, so 0.01 is exactly one hundredth
+	// rather than the nearest binary fraction
+	rf := registeredFamily{UnitFamily: f}
+	rf.Units = append([]Unit(nil), f.Units...)
+	for _, u := range rf.Units {
+		m := new(big.Rat).SetFloat64(u.Multiplier)
+		if u.Multiplier != math.Trunc(u.Multiplier) {
+			m.SetString(strconv.FormatFloat(u.Multiplier, 'g', -1, 64))
+		}
+		rf.multipliers = append(rf.multipliers, m)
+	}
+	r.families = append(r.families, rf)
+	sort.SliceStable(r.families, func(i, j int) bool {
+		return r.families[i].Precedence < r.families[j].Precedence
+	})
+
+	for _, u := range f.Units {
+		for _, c := range u.Suffix {
+			if !isLetterRune(c) && !isDigitRune(c) && !strings.ContainsRune(r.extra, c) {
+				r.extra += string(c)
+			}
+		}
+	}
+	return nil
+}
+
+// suffixesOverlap reports whether some input would be matched by both suffixes
+func suffixesOverlap(a string, aFold bool, b string, bFold bool) bool {
+	if aFold || bFold {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// isSuffixChar reports whether c is a non-alphanumeric character used by a registered suffix
+func (r *UnitRegistry) isSuffixChar(c rune) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return strings.ContainsRune(r.extra, c)
+}
+
+// lookup converts a humanized literal like "1.5GiB" using the first family, in
+// precedence order, that knows its suffix. The result is exact.
+func (r *UnitRegistry) lookup(s string) (*big.Rat, bool) {
+	return r.lookupFamily(s, "")
+}
+
+// lookupFamily is like lookup, but only considers the named family when family is not empty
+func (r *UnitRegistry) lookupFamily(s string, family string) (*big.Rat, bool) {
+	numStr, suffix := splitNumericPrefix(strings.TrimSpace(s))
+	if numStr == "" || suffix == "" {
+		return nil, false
+	}
+	num, err := parseNumericLiteral(numStr)
+	if err != nil {
+		return nil, false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, f := range r.families {
+		if family != "" && f.Name != family {
+			continue
+		}
+		for i, u := range f.Units {
+			if u.Suffix == suffix || (f.CaseInsensitive && strings.EqualFold(u.Suffix, suffix)) {
+				return num.Mul(num, f.multipliers[i]), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// splitNumericPrefix splits "1.5e3KiB" into "1.5e3" and "KiB". The numeric part may
+// contain digits, commas, a decimal point and an exponent.
+func splitNumericPrefix(s string) (string, string) {
+	i := 0
+	for i < len(s) && (isDigit(s[i]) || s[i] == ',') {
+		i++
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		for i < len(s) && isDigit(s[i]) {
+			i++
+		}
+	}
+	if i == 0 || !strings.ContainsAny(s[:i], "0123456789") {
+		return "", s
+	}
+
+	// Only treat e/E as an exponent when digits follow, so "2E" stays exa and "5EB" stays exabytes
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		if j < len(s) && isDigit(s[j]) {
+			for j < len(s) && isDigit(s[j]) {
+				j++
+			}
+			i = j
+		}
+	}
+	return s[:i], s[i:]
+}
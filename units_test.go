@@ -0,0 +1,191 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnitRegistryConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		families []UnitFamily
+		wantErr  string
+	}{
+		{
+			name: "Same suffix at same precedence",
+			families: []UnitFamily{
+				{Name: "latency", Precedence: 5, Units: []Unit{{"ms", 0.001}}},
+				{Name: "other", Precedence: 5, Units: []Unit{{"ms", 1}}},
+			},
+			wantErr: "conflicts with unit",
+		},
+		{
+			name: "Case-insensitive family overlaps case-sensitive one",
+			families: []UnitFamily{
+				{Name: "si", Precedence: 5, Units: []Unit{{"M", 1e6}}},
+				{Name: "time", Precedence: 5, CaseInsensitive: true, Units: []Unit{{"m", 60}}},
+			},
+			wantErr: "conflicts with unit",
+		},
+		{
+			name: "Different case without folding is fine",
+			families: []UnitFamily{
+				{Name: "si", Precedence: 5, Units: []Unit{{"M", 1e6}}},
+				{Name: "time", Precedence: 5, Units: []Unit{{"m", 60}}},
+			},
+		},
+		{
+			name: "Different precedence is resolved by precedence",
+			families: []UnitFamily{
+				{Name: "a", Precedence: 1, Units: []Unit{{"x", 2}}},
+				{Name: "b", Precedence: 2, Units: []Unit{{"x", 3}}},
+			},
+		},
+		{
+			name: "Duplicate suffix within a family",
+			families: []UnitFamily{
+				{Name: "bw", CaseInsensitive: true, Units: []Unit{{"Mbps", 1e6}, {"mbps", 1e6}}},
+			},
+			wantErr: "conflicts with",
+		},
+		{
+			name: "Duplicate family name",
+			families: []UnitFamily{
+				{Name: "rate", Precedence: 1, Units: []Unit{{"rps", 1}}},
+				{Name: "rate", Precedence: 2, Units: []Unit{{"rpm", 1}}},
+			},
+			wantErr: "already registered",
+		},
+		{
+			name: "Digits in suffix",
+			families: []UnitFamily{
+				{Name: "bad", Units: []Unit{{"x2", 1}}},
+			},
+			wantErr: "invalid suffix",
+		},
+		{
+			name: "Zero multiplier",
+			families: []UnitFamily{
+				{Name: "bad", Units: []Unit{{"z", 0}}},
+			},
+			wantErr: "invalid multiplier",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewUnitRegistry()
+			var err error
+			for _, f := range tt.families {
+				if err = r.Register(f); err != nil {
+					break
+				}
+			}
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestUnitRegistryPrecedence(t *testing.T) {
+	r := NewUnitRegistry()
+	if err := r.Register(UnitFamily{Name: "low", Precedence: 2, Units: []Unit{{"x", 3}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register(UnitFamily{Name: "high", Precedence: 1, Units: []Unit{{"x", 2}}}); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := r.lookup("10x"); !ok || formatRat(v) != "20" {
+		t.Errorf("lookup(10x) = %v, %v; want 20, true", v, ok)
+	}
+	if _, ok := r.lookup("10y"); ok {
+		t.Errorf("lookup(10y) should not match")
+	}
+}
+
+func TestCustomUnitFamilies(t *testing.T) {
+	families := []UnitFamily{
+		{Name: "test-rate", Precedence: 40, Units: []Unit{{"rps", 1}, {"krps", 1000}}},
+		{Name: "test-percent", Precedence: 40, Units: []Unit{{"%", 0.01}}},
+		{Name: "test-bandwidth", Precedence: 40, CaseInsensitive: true, Units: []Unit{{"Mbps", 1e6}, {"Gbps", 1e9}}},
+	}
+	for _, f := range families {
+		if err := RegisterUnitFamily(f); err != nil {
+			t.Fatalf("RegisterUnitFamily(%s): %v", f.Name, err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Rate > 500rps", "Rate > 500"},
+		{"Rate > 1.5krps", "Rate > 1500"},
+		{"Usage > 50%", "Usage > 0.5"},
+		{"Link >= 10Mbps", "Link >= 10000000"},
+		{"Link >= 1gbps", "Link >= 1000000000"},
+		{"Name = '50%'", "Name = '50%'"},
+		// Built-in families keep their precedence over custom ones
+		{"Duration > 10m AND Size > 1GB", "Duration > 600 AND Size > 1000000000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result := normalizeHumanizedValues(tt.input)
+			if result != tt.expected {
+				t.Errorf("normalizeHumanizedValues(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+
+	type Service struct {
+		Name  string
+		Usage float64
+	}
+	services := []Service{{"api", 0.75}, {"db", 0.25}}
+	results, err := Parse("Usage > 50%", services)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if len(results) != 1 || results[0].Name != "api" {
+		t.Errorf("expected only api, got %+v", results)
+	}
+}
+
+func TestWithUnitRegistry(t *testing.T) {
+	units := DefaultUnits.Clone()
+	if err := units.Register(UnitFamily{Name: "test-queries", Precedence: 40, Units: []Unit{{"qps", 1}, {"kqps", 1000}}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := DefaultUnits.lookup("10qps"); ok {
+		t.Fatalf("registering on a clone changed DefaultUnits")
+	}
+
+	type Service struct {
+		Name    string
+		Rate    float64
+		Timeout int
+	}
+	services := []Service{{"api", 2500, 300}, {"db", 800, 900}}
+
+	results, err := Parse("Rate > 1.5kqps AND Timeout < 10m", services, WithUnitRegistry(units))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if len(results) != 1 || results[0].Name != "api" {
+		t.Errorf("expected only api, got %+v", results)
+	}
+
+	if _, err := Parse("Rate > 1.5kqps", services); err == nil {
+		t.Errorf("expected an error without WithUnitRegistry")
+	}
+
+	// An empty registry turns off unit conversion
+	if _, err := Parse("Timeout < 10m", services, WithUnitRegistry(NewUnitRegistry())); err == nil {
+		t.Errorf("expected an error with an empty registry")
+	}
+}
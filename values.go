@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"database/sql/driver"
+	"encoding"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+var (
+	valuerType        = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	stringerType      = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// implementation returns v as an iface value. Types whose methods have pointer
+// receivers are handled by calling the method on a copy of v.
+func implementation(v reflect.Value, iface reflect.Type) (any, bool) {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil, false
+	}
+	if v.Type().Implements(iface) {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return nil, false
+		}
+		return v.Interface(), true
+	}
+	if v.Kind() != reflect.Ptr && v.Kind() != reflect.Interface && reflect.PointerTo(v.Type()).Implements(iface) {
+		if v.CanAddr() {
+			return v.Addr().Interface(), true
+		}
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		return p.Interface(), true
+	}
+	return nil, false
+}
+
+// cachedInterfaces are the interfaces implementsCache records for each type
+var cachedInterfaces = []reflect.Type{valuerType, stringerType, textMarshalerType, decimalComparerType}
+
+// implementsCache remembers which of cachedInterfaces a type implements, as a bit
+// per interface, since the check runs for every compared value
+var implementsCache sync.Map // map[reflect.Type]uint
+
+// implementsAny reports whether t or *t implements iface
+func implementsAny(t reflect.Type, iface reflect.Type) bool {
+	bit := slices.Index(cachedInterfaces, iface)
+	if bit < 0 {
+		return implements(t, iface)
+	}
+	bits, found := implementsCache.Load(t)
+	if !found {
+		var b uint
+		for i, iface := range cachedInterfaces {
+			if implements(t, iface) {
+				b |= 1 << i
+			}
+		}
+		bits, _ = implementsCache.LoadOrStore(t, b)
+	}
+	return bits.(uint)&(1<<bit) != 0
+}
+
+// implements is implementsAny without the cache
+func implements(t reflect.Type, iface reflect.Type) bool {
+	return t.Implements(iface) || (t.Kind() != reflect.Ptr && t.Kind() != reflect.Interface && reflect.PointerTo(t).Implements(iface))
+}
+
+// hasTextForm reports whether values of t compare by their text, through
+// MarshalText or String
+func hasTextForm(t reflect.Type) bool {
+	return implementsAny(t, textMarshalerType) || implementsAny(t, stringerType)
+}
+
+// resolveValuer replaces driver.Valuer values such as sql.NullString with the value
+// they hold. isNull is true if the Valuer reports NULL (e.g. Valid is false).
+func resolveValuer(v reflect.Value) (resolved reflect.Value, isNull bool, err error) {
+	if !v.IsValid() || !implementsAny(v.Type(), valuerType) {
+		return v, false, nil
+	}
+	impl, ok := implementation(v, valuerType)
+	if !ok {
+		return v, false, nil
+	}
+	dv, err := impl.(driver.Valuer).Value()
+	if err != nil {
+		return v, false, err
+	}
+	if dv == nil {
+		return reflect.Value{}, true, nil
+	}
+	if b, ok := dv.([]byte); ok {
+		dv = string(b)
+	}
+	return reflect.ValueOf(dv), false, nil
+}
+
+// textValue returns the text form of values implementing encoding.TextMarshaler or
+// fmt.Stringer, preferring TextMarshaler since it is meant to round-trip.
+func textValue(v reflect.Value) (string, bool, error) {
+	if !v.IsValid() {
+		return "", false, nil
+	}
+	if implementsAny(v.Type(), textMarshalerType) {
+		if impl, ok := implementation(v, textMarshalerType); ok {
+			b, err := impl.(encoding.TextMarshaler).MarshalText()
+			if err != nil {
+				return "", true, err
+			}
+			return string(b), true, nil
+		}
+	}
+	if implementsAny(v.Type(), stringerType) {
+		if impl, ok := implementation(v, stringerType); ok {
+			return impl.(fmt.Stringer).String(), true, nil
+		}
+	}
+	return "", false, nil
+}
@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+)
+
+type Status int
+
+const (
+	StatusPending Status = iota
+	StatusActive
+	StatusSuspended
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusPending:
+		return "Pending"
+	case StatusActive:
+		return "Active"
+	case StatusSuspended:
+		return "Suspended"
+	}
+	return fmt.Sprintf("Status(%d)", int(s))
+}
+
+// AccountID marshals itself as text with a prefix
+type AccountID struct {
+	n int
+}
+
+func (id AccountID) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("acct-%04d", id.n)), nil
+}
+
+// Code has a pointer-receiver String method
+type Code [2]byte
+
+func (c *Code) String() string {
+	return string(c[:])
+}
+
+func TestInterfaceFieldTypes(t *testing.T) {
+	type Account struct {
+		Name     string
+		Status   Status
+		ID       AccountID
+		Country  Code
+		Nickname sql.NullString
+		Score    sql.NullInt64
+		Ratio    sql.NullFloat64
+		Verified sql.NullBool
+		Parent   *sql.NullString
+	}
+
+	accounts := []Account{
+		{
+			Name: "a", Status: StatusActive, ID: AccountID{1}, Country: Code{'N', 'O'},
+			Nickname: sql.NullString{String: "ace", Valid: true},
+			Score:    sql.NullInt64{Int64: 10, Valid: true},
+			Ratio:    sql.NullFloat64{Float64: 0.5, Valid: true},
+			Verified: sql.NullBool{Bool: true, Valid: true},
+		},
+		{
+			Name: "b", Status: StatusSuspended, ID: AccountID{2}, Country: Code{'S', 'E'},
+			Nickname: sql.NullString{String: "stale", Valid: false},
+			Score:    sql.NullInt64{Int64: 0, Valid: true},
+			Parent:   &sql.NullString{String: "a", Valid: true},
+		},
+		{
+			Name: "c", Status: StatusPending, ID: AccountID{3}, Country: Code{'N', 'O'},
+			Parent: &sql.NullString{},
+		},
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"Status = 'Active'", []string{"a"}},
+		{"Status = 'active'", []string{"a"}},
+		{"Status != 'Active'", []string{"b", "c"}},
+		{"Status = 1", []string{"a"}},
+		{"Status > 0", []string{"a", "b"}},
+		{"ANY(Status) = ANY('Pending', 'Suspended')", []string{"b", "c"}},
+		{"ID = 'acct-0002'", []string{"b"}},
+		{"ID CONTAINS '000'", []string{"a", "b", "c"}},
+		{"Country = 'NO'", []string{"a", "c"}},
+		{"Nickname = 'ace'", []string{"a"}},
+		{"Nickname = 'stale'", nil},
+		{"Nickname IS NULL", []string{"b", "c"}},
+		{"Nickname IS NOT NULL", []string{"a"}},
+		{"Score >= 0", []string{"a", "b"}},
+		{"Score IS NULL", []string{"c"}},
+		{"Ratio < 1", []string{"a"}},
+		{"Verified = true", []string{"a"}},
+		{"Parent = 'a'", []string{"b"}},
+		{"Parent IS NULL", []string{"a", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, err := Parse(tt.query, accounts)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.query, err)
+			}
+			checkNames(t, tt.query, results, tt.expected)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package parser
+
+// walkExpression calls fn for expr and, depth-first, every expression nested in it.
+// Children are skipped when fn returns false.
+func walkExpression(expr Expression, fn func(Expression) bool) {
+	if expr == nil || !fn(expr) {
+		return
+	}
+	switch e := expr.(type) {
+	case *NotExpression:
+		walkExpression(e.Expression, fn)
+	case *ConjunctionExpression:
+		for _, child := range e.Expressions {
+			walkExpression(child, fn)
+		}
+	case *OrExpression:
+		for _, child := range e.Expressions {
+			walkExpression(child, fn)
+		}
+	case *QuantifierExpression:
+		walkExpression(e.Predicate, fn)
+	case *SetExpression:
+		if e.List != nil {
+			walkExpression(e.List, fn)
+		}
+	}
+}
+
+// expressionFields returns the field paths an expression reads itself
+func expressionFields(expr Expression) []string {
+	switch e := expr.(type) {
+	case *ComparisonExpression:
+		return []string{e.Field}
+	case *AnyExpression:
+		return []string{e.Field}
+	case *IsNullExpression:
+		return []string{e.Field}
+	case *IsEmptyExpression:
+		return []string{e.Field}
+	case *IsZeroExpression:
+		return []string{e.Field}
+	case *IsTypeExpression:
+		if e.Field != "" {
+			return []string{e.Field}
+		}
+	case *HasKeyExpression:
+		return []string{e.Field, e.keyPath()}
+	case *SetExpression:
+		if e.Other != "" {
+			return []string{e.Field, e.Other}
+		}
+		return []string{e.Field}
+	}
+	return nil
+}